@@ -0,0 +1,235 @@
+package okhttp
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+// charsetPrescan is the number of bytes searched for <meta charset> like browsers do
+const charsetPrescan = 1024
+
+var xmlEncodingDecl = regexp.MustCompile(`^\s*<\?xml[^>]*?\sencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+// lookupCharset returns the encoding of a charset label like gbk, big5 or shift_jis and its canonical name
+func lookupCharset(label string) (encoding.Encoding, string, error) {
+	e, name := charset.Lookup(label)
+	if e == nil {
+		return nil, "", fmt.Errorf("unknown charset %q", label)
+	}
+	return e, name, nil
+}
+
+// detectCharset returns the charset of a body from its BOM, the charset parameter of contentType,
+// then <meta charset> of HTML or the encoding declaration of XML, "" when none is declared
+func detectCharset(body []byte, contentType string) (name string, bom int) {
+	switch {
+	case bytes.HasPrefix(body, []byte("\xef\xbb\xbf")):
+		return "utf-8", 3
+	case bytes.HasPrefix(body, []byte("\xfe\xff")):
+		return "utf-16be", 2
+	case bytes.HasPrefix(body, []byte("\xff\xfe")):
+		return "utf-16le", 2
+	}
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	if label := params["charset"]; label != "" {
+		if _, name, err := lookupCharset(label); err == nil {
+			return name, 0
+		}
+	}
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		if label := prescanMetaCharset(body); label != "" {
+			if _, name, err := lookupCharset(label); err == nil {
+				return name, 0
+			}
+		}
+	case isXMLMediaType(mediaType):
+		if m := xmlEncodingDecl.FindSubmatch(body); m != nil {
+			if _, name, err := lookupCharset(string(m[1])); err == nil {
+				return name, 0
+			}
+		}
+	}
+	return "", 0
+}
+
+// prescanMetaCharset returns the charset of the first <meta charset> or <meta http-equiv="Content-Type"> of a document
+func prescanMetaCharset(body []byte) string {
+	if len(body) > charsetPrescan {
+		body = body[:charsetPrescan]
+	}
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) != "meta" {
+				continue
+			}
+			var httpEquiv, content string
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				switch string(key) {
+				case "charset":
+					return strings.TrimSpace(string(val))
+				case "http-equiv":
+					httpEquiv = strings.ToLower(string(val))
+				case "content":
+					content = string(val)
+				}
+			}
+			if httpEquiv == "content-type" {
+				if _, params, err := mime.ParseMediaType(content); err == nil && params["charset"] != "" {
+					return params["charset"]
+				}
+			}
+		}
+	}
+}
+
+func isXMLMediaType(mediaType string) bool {
+	return mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+// isTextMediaType reports the media types which are transcoded to UTF-8 before decoding
+func isTextMediaType(mediaType string) bool {
+	switch {
+	case mediaType == "", strings.HasPrefix(mediaType, "text/"), isXMLMediaType(mediaType),
+		mediaType == "application/json", strings.HasSuffix(mediaType, "+json"),
+		mediaType == "application/x-www-form-urlencoded", strings.HasSuffix(mediaType, "yaml"),
+		mediaType == "application/javascript":
+		return true
+	}
+	return false
+}
+
+// transcodeToUTF8 decodes a body in a charset to UTF-8, dropping the BOM
+func transcodeToUTF8(body []byte, name string, bom int) ([]byte, error) {
+	body = body[bom:]
+	if name == "" || name == "utf-8" {
+		return body, nil
+	}
+	e, _, err := lookupCharset(name)
+	if err != nil {
+		return nil, err
+	}
+	return e.NewDecoder().Bytes(body)
+}
+
+// utf8XMLDeclaration rewrites the encoding declaration of a transcoded XML document to UTF-8
+func utf8XMLDeclaration(body []byte) []byte {
+	m := xmlEncodingDecl.FindSubmatchIndex(body)
+	if m == nil {
+		return body
+	}
+	out := make([]byte, 0, len(body))
+	out = append(out, body[:m[2]]...)
+	out = append(out, "UTF-8"...)
+	return append(out, body[m[3]:]...)
+}
+
+// encodeFormCharset encodes the keys and values of a form in a charset before they are percent-encoded
+func encodeFormCharset(v url.Values, name string) (url.Values, error) {
+	e, _, err := lookupCharset(name)
+	if err != nil {
+		return nil, err
+	}
+	enc := e.NewEncoder()
+	out := make(url.Values, len(v))
+	for key, values := range v {
+		k, err := enc.String(key)
+		if err != nil {
+			return nil, fmt.Errorf("form charset %s: %w", name, err)
+		}
+		for _, value := range values {
+			s, err := enc.String(value)
+			if err != nil {
+				return nil, fmt.Errorf("form charset %s: %w", name, err)
+			}
+			out[k] = append(out[k], s)
+		}
+	}
+	return out, nil
+}
+
+// SetResponseCharset forces the charset of the responses of every request, "" detects it
+func (c *Client) SetResponseCharset(name string) *Client {
+	if name != "" {
+		if _, _, err := lookupCharset(name); err != nil {
+			c.errs = append(c.errs, err)
+			return c
+		}
+	}
+	c.responseCharset = name
+	return c
+}
+
+// SetResponseCharset forces the charset of the response, "" detects it
+func (r *Request) SetResponseCharset(name string) *Request {
+	if name != "" {
+		if _, _, err := lookupCharset(name); err != nil {
+			return r.addError(err)
+		}
+	}
+	r.responseCharset = name
+	return r
+}
+
+// SetFormCharset encodes form bodies in a charset like gbk instead of UTF-8
+func (r *Request) SetFormCharset(name string) *Request {
+	if _, _, err := lookupCharset(name); err != nil {
+		return r.addError(err)
+	}
+	r.formCharset = name
+	if r.form != nil {
+		return r.SetForm(r.form)
+	}
+	return r
+}
+
+// Charset returns the charset of the response body, forced or detected, utf-8 when none is declared
+func (r *Response) Charset() string {
+	r.decodeText()
+	return r.charset
+}
+
+// text returns the body transcoded to UTF-8
+func (r *Response) text() ([]byte, error) {
+	r.decodeText()
+	return r.textBody, r.textErr
+}
+
+func (r *Response) decodeText() {
+	r.textOnce.Do(func() {
+		contentType := r.GetHeader("Content-Type")
+		name, bom := detectCharset(r.body, contentType)
+		if r.request != nil && r.request.responseCharset != "" {
+			_, name, _ = lookupCharset(r.request.responseCharset)
+		}
+		if name == "" {
+			name = "utf-8"
+		}
+		r.charset = name
+		r.textBody, r.textErr = transcodeToUTF8(r.body, name, bom)
+		if r.textErr != nil {
+			r.textBody = r.body
+			return
+		}
+		if name != "utf-8" {
+			if mediaType, _, _ := mime.ParseMediaType(contentType); isXMLMediaType(mediaType) {
+				r.textBody = utf8XMLDeclaration(r.textBody)
+			}
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package okhttp
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func Test_ResponseCharset(t *testing.T) {
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().String(`{"foo":"你好"}`)
+	big5, _ := traditionalchinese.Big5.NewEncoder().String(`<html><head><meta charset="big5"></head><body>繁體</body></html>`)
+	gb18030, _ := simplifiedchinese.GB18030.NewEncoder().String(`<?xml version="1.0" encoding="GB18030"?><item><foo>中文</foo></item>`)
+	utf16, _ := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().String(`{"foo":"utf16"}`)
+
+	bodies := map[string][2]string{
+		"/gbk":     {"application/json; charset=GBK", gbk},
+		"/big5":    {"text/html", big5},
+		"/gb18030": {"application/xml", gb18030},
+		"/utf16":   {"application/json", utf16},
+		"/forced":  {"text/plain", gbk},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", bodies[r.URL.Path][0])
+		w.Write([]byte(bodies[r.URL.Path][1]))
+	}))
+	defer ts.Close()
+
+	get := func(path string) *Response {
+		req, _ := Get(ts.URL + path)
+		if path == "/forced" {
+			req.SetResponseCharset("gb18030")
+		}
+		resp, err := req.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	var v testStruct
+	resp := get("/gbk")
+	if err := resp.GetJSON(&v); err != nil || v.Foo != "你好" {
+		t.Errorf(`Foo should be "%s", "%s" given, %v`, "你好", v.Foo, err)
+	}
+	if resp.Charset() != "gbk" {
+		t.Errorf(`Charset should be "%s", "%s" given`, "gbk", resp.Charset())
+	}
+	if resp.GetString("foo") != "你好" {
+		t.Errorf(`GetString should be "%s", "%s" given`, "你好", resp.GetString("foo"))
+	}
+
+	resp = get("/big5")
+	if want := `<html><head><meta charset="big5"></head><body>繁體</body></html>`; resp.String() != want {
+		t.Errorf(`String should be "%s", "%s" given`, want, resp.String())
+	}
+
+	var item struct {
+		XMLName xml.Name `xml:"item"`
+		Foo     string   `xml:"foo"`
+	}
+	if err := get("/gb18030").Decode(&item); err != nil || item.Foo != "中文" {
+		t.Errorf(`Foo should be "%s", "%s" given, %v`, "中文", item.Foo, err)
+	}
+
+	v = testStruct{}
+	if err := get("/utf16").Decode(&v); err != nil || v.Foo != "utf16" {
+		t.Errorf(`Foo should be "%s", "%s" given, %v`, "utf16", v.Foo, err)
+	}
+
+	if get("/forced").String() != `{"foo":"你好"}` {
+		t.Errorf(`String should be "%s", "%s" given`, `{"foo":"你好"}`, get("/forced").String())
+	}
+
+	req, _ := Get(ts.URL)
+	if req.SetResponseCharset("no-such-charset").Err() == nil {
+		t.Errorf(`SetResponseCharset of an unknown charset should be an error`)
+	}
+}
+
+func Test_FormCharset(t *testing.T) {
+	var body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer ts.Close()
+
+	req, _ := Post(ts.URL)
+	req.SetFormStruct(struct {
+		Name string `form:"name"`
+	}{"中文"}).SetFormCharset("gbk")
+	if _, err := req.Do(); err != nil {
+		t.Fatal(err)
+	}
+	if body != "name=%D6%D0%CE%C4" {
+		t.Errorf(`body should be "%s", "%s" given`, "name=%D6%D0%CE%C4", body)
+	}
+}
@@ -0,0 +1,187 @@
+package okhttp
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"reflect"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Client holds the settings shared by the requests it creates
+type Client struct {
+	dialContext  DialContextFunc
+	proxyPool    *ProxyPool
+	timeout      time.Duration
+	protocol     Protocol
+	http2Options *HTTP2Options
+	tlsConfig    *tls.Config
+
+	pingInterval            time.Duration
+	disableWebSocketDeflate bool
+	maxMessageSize          int64
+
+	errorPolicy StatusPolicy
+	errorResult reflect.Type
+	envelope    *Envelope
+
+	responseCharset string
+	jar             http.CookieJar
+
+	eventListeners []EventListenerFactory
+	tracer         *Tracer
+	logger         Logger
+	redactor       *Redactor
+
+	errs []error
+}
+
+// NewClient created a client
+func NewClient() *Client {
+	return &Client{}
+}
+
+// SetCookieJar set the cookie jar shared by every request, it keeps the session cookies between requests
+func (c *Client) SetCookieJar(jar http.CookieJar) *Client {
+	c.jar = jar
+	return c
+}
+
+// EnableCookieJar set a new in memory cookie jar shared by every request
+func (c *Client) EnableCookieJar() *Client {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		c.errs = append(c.errs, err)
+		return c
+	}
+	return c.SetCookieJar(jar)
+}
+
+// CookieJar returns the cookie jar of the client, nil when the requests do not share cookies
+func (c *Client) CookieJar() http.CookieJar {
+	return c.jar
+}
+
+// Err returns the errors recorded by the setters, every request of the client returns them from Do
+func (c *Client) Err() error {
+	return errors.Join(c.errs...)
+}
+
+// SetDialContext set the dialer of every request
+func (c *Client) SetDialContext(dial DialContextFunc) *Client {
+	c.dialContext = dial
+	return c
+}
+
+// SetUnixSocket connects every request to a unix socket like unix:///var/run/docker.sock
+func (c *Client) SetUnixSocket(address string) *Client {
+	dial, err := UnixDialer(address)
+	if err != nil {
+		c.errs = append(c.errs, err)
+		return c
+	}
+	return c.SetDialContext(dial)
+}
+
+// SetProxyPool set a proxy pool for every request
+func (c *Client) SetProxyPool(pool *ProxyPool) *Client {
+	c.proxyPool = pool
+	return c
+}
+
+// SetTimeOut set the default time out of every request
+func (c *Client) SetTimeOut(d time.Duration) *Client {
+	c.timeout = d
+	return c
+}
+
+// SetProtocol set the http version spoken by every request
+func (c *Client) SetProtocol(p Protocol) *Client {
+	c.protocol = p
+	return c
+}
+
+// SetHTTP2Options set the knobs of HTTP/2 connections
+func (c *Client) SetHTTP2Options(o HTTP2Options) *Client {
+	c.http2Options = &o
+	return c
+}
+
+// SetTLSConfig set the tls config of every request
+func (c *Client) SetTLSConfig(cfg *tls.Config) *Client {
+	c.tlsConfig = cfg
+	return c
+}
+
+// NewRequest 建立一个使用 client 配置的请求
+func (c *Client) NewRequest(method, uri string) (*Request, error) {
+	r, err := NewRequest(method, uri)
+	if err != nil {
+		return nil, err
+	}
+	r.errs = append(r.errs, c.errs...)
+	r.dialContext = c.dialContext
+	r.protocol = c.protocol
+	r.http2Options = c.http2Options
+	r.tlsConfig = c.tlsConfig
+	if c.proxyPool != nil {
+		r.SetProxyPool(c.proxyPool)
+	}
+	if c.timeout > 0 {
+		r.SetTimeOut(c.timeout)
+	}
+	r.errorPolicy = c.errorPolicy
+	r.envelope = c.envelope
+	r.responseCharset = c.responseCharset
+	r.jar = c.jar
+	r.eventListeners = append(r.eventListeners, c.eventListeners...)
+	r.tracer = c.tracer
+	if c.redactor != nil {
+		r.SetRedactor(c.redactor)
+	}
+	if c.logger != nil {
+		r.l = c.logger
+	}
+	if c.errorResult != nil {
+		r.SetErrorResult(reflect.New(c.errorResult).Interface())
+	}
+	return r, nil
+}
+
+// Get created a get request
+func (c *Client) Get(uri string) (*Request, error) {
+	return c.NewRequest(http.MethodGet, uri)
+}
+
+// Post created a post request
+func (c *Client) Post(uri string) (*Request, error) {
+	return c.NewRequest(http.MethodPost, uri)
+}
+
+// Put created a put request
+func (c *Client) Put(uri string) (*Request, error) {
+	return c.NewRequest(http.MethodPut, uri)
+}
+
+// Delete created a delete request
+func (c *Client) Delete(uri string) (*Request, error) {
+	return c.NewRequest(http.MethodDelete, uri)
+}
+
+// Head created a head request
+func (c *Client) Head(uri string) (*Request, error) {
+	return c.NewRequest(http.MethodHead, uri)
+}
+
+// Patch created a patch request
+func (c *Client) Patch(uri string) (*Request, error) {
+	return c.NewRequest(http.MethodPatch, uri)
+}
+
+// Options created a options request
+func (c *Client) Options(uri string) (*Request, error) {
+	return c.NewRequest(http.MethodOptions, uri)
+}
@@ -0,0 +1,148 @@
+package okhttp
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// NoMatchCodec is returned when no codec is registered for a media type
+var NoMatchCodec = errors.New("no match codec for media type")
+
+// Codec encodes and decodes the bodies of a media type
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var codecs = struct {
+	sync.RWMutex
+	m map[string]Codec
+}{m: map[string]Codec{}}
+
+func init() {
+	RegisterCodec("application/json", JSONCodec{})
+	RegisterCodec("application/xml", XMLCodec{})
+	RegisterCodec("text/xml", XMLCodec{})
+	RegisterCodec("application/x-protobuf", ProtobufCodec{})
+	RegisterCodec("application/protobuf", ProtobufCodec{})
+	RegisterCodec("application/vnd.google.protobuf", ProtobufCodec{})
+	RegisterCodec("application/msgpack", MsgPackCodec{})
+	RegisterCodec("application/x-msgpack", MsgPackCodec{})
+	RegisterCodec("application/vnd.msgpack", MsgPackCodec{})
+	RegisterCodec("application/yaml", YAMLCodec{})
+	RegisterCodec("application/x-yaml", YAMLCodec{})
+	RegisterCodec("text/yaml", YAMLCodec{})
+	RegisterCodec("application/cbor", CBORCodec{})
+	RegisterCodec("application/x-www-form-urlencoded", FormCodec{})
+}
+
+// RegisterCodec register the codec of a media type, it replaces the codec registered before,
+// e.g. RegisterCodec("application/json", c) plugs a faster JSON implementation
+func RegisterCodec(mediaType string, c Codec) {
+	codecs.Lock()
+	codecs.m[strings.ToLower(mediaType)] = c
+	codecs.Unlock()
+}
+
+// LookupCodec returns the codec of a content type like "application/problem+json; charset=utf-8",
+// a structured syntax suffix falls back to the codec of its base type
+func LookupCodec(contentType string) (Codec, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q", NoMatchCodec, contentType)
+	}
+	codecs.RLock()
+	defer codecs.RUnlock()
+	if c, ok := codecs.m[mediaType]; ok {
+		return c, nil
+	}
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		if c, ok := codecs.m["application/"+mediaType[i+1:]]; ok {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q", NoMatchCodec, contentType)
+}
+
+// JSONCodec is the codec of application/json
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// XMLCodec is the codec of application/xml and text/xml
+type XMLCodec struct{}
+
+func (XMLCodec) Marshal(v interface{}) ([]byte, error)      { return xml.Marshal(v) }
+func (XMLCodec) Unmarshal(data []byte, v interface{}) error { return xml.Unmarshal(data, v) }
+
+// ProtobufCodec is the codec of application/x-protobuf, values must be proto.Message
+type ProtobufCodec struct{}
+
+func (ProtobufCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf codec: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (ProtobufCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf codec: %T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// MsgPackCodec is the codec of application/msgpack
+type MsgPackCodec struct{}
+
+func (MsgPackCodec) Marshal(v interface{}) ([]byte, error)      { return msgpack.Marshal(v) }
+func (MsgPackCodec) Unmarshal(data []byte, v interface{}) error { return msgpack.Unmarshal(data, v) }
+
+// YAMLCodec is the codec of application/yaml
+type YAMLCodec struct{}
+
+func (YAMLCodec) Marshal(v interface{}) ([]byte, error)      { return yaml.Marshal(v) }
+func (YAMLCodec) Unmarshal(data []byte, v interface{}) error { return yaml.Unmarshal(data, v) }
+
+// CBORCodec is the codec of application/cbor
+type CBORCodec struct{}
+
+func (CBORCodec) Marshal(v interface{}) ([]byte, error)      { return cbor.Marshal(v) }
+func (CBORCodec) Unmarshal(data []byte, v interface{}) error { return cbor.Unmarshal(data, v) }
+
+// FormCodec is the codec of application/x-www-form-urlencoded, structs use tags like `form:"name,omitempty"`
+type FormCodec struct{}
+
+func (FormCodec) Marshal(v interface{}) ([]byte, error) {
+	values, err := valuesEncoder{tag: "form"}.encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(values.Encode()), nil
+}
+
+func (FormCodec) Unmarshal(data []byte, v interface{}) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	if p, ok := v.(*url.Values); ok {
+		*p = values
+		return nil
+	}
+	return valuesDecoder{tag: "form"}.decode(values, v)
+}
@@ -0,0 +1,115 @@
+package okhttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type codecStruct struct {
+	Foo  string `json:"foo" xml:"foo" msgpack:"foo" yaml:"foo" cbor:"foo"`
+	Fizz int    `json:"fizz" xml:"fizz" msgpack:"fizz" yaml:"fizz" cbor:"fizz"`
+}
+
+// newEchoServer answers the request body with the request Content-Type
+func newEchoServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		w.Write(body)
+	}))
+}
+
+func Test_Codecs(t *testing.T) {
+	ts := newEchoServer(t)
+	defer ts.Close()
+
+	for _, contentType := range []string{
+		"application/json",
+		"application/problem+json",
+		"application/xml",
+		"application/msgpack",
+		"application/yaml",
+		"application/cbor",
+	} {
+		req, _ := Post(ts.URL)
+		resp, err := req.SetBodyAs(contentType, codecStruct{Foo: "bar", Fizz: 42}).Do()
+		if err != nil {
+			t.Fatal(contentType, err)
+		}
+		var v codecStruct
+		if err := resp.Decode(&v); err != nil {
+			t.Fatal(contentType, err)
+		}
+		if v.Foo != "bar" || v.Fizz != 42 {
+			t.Errorf(`%s should decode to {bar 42}, %v given`, contentType, v)
+		}
+	}
+}
+
+func Test_ProtobufCodec(t *testing.T) {
+	ts := newEchoServer(t)
+	defer ts.Close()
+
+	req, _ := Post(ts.URL)
+	resp, err := req.SetBodyAs("application/x-protobuf", wrapperspb.String("okhttp")).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &wrapperspb.StringValue{}
+	if err := resp.Decode(v); err != nil {
+		t.Fatal(err)
+	}
+	if v.GetValue() != "okhttp" {
+		t.Errorf(`Should be "%s", "%s" given`, "okhttp", v.GetValue())
+	}
+}
+
+type upperCodec struct{}
+
+func (upperCodec) Marshal(v interface{}) ([]byte, error) {
+	return []byte(strings.ToUpper(v.(string))), nil
+}
+
+func (upperCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*string) = strings.ToLower(string(data))
+	return nil
+}
+
+func Test_RegisterCodec(t *testing.T) {
+	RegisterCodec("text/x-upper", upperCodec{})
+	ts := newEchoServer(t)
+	defer ts.Close()
+
+	req, _ := Post(ts.URL)
+	resp, err := req.SetBodyAs("text/x-upper; charset=utf-8", "Hello").Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.String() != "HELLO" {
+		t.Errorf(`Body should be "%s", "%s" given`, "HELLO", resp.String())
+	}
+	var v string
+	if err := resp.Decode(&v); err != nil || v != "hello" {
+		t.Errorf(`Should be "%s", "%s" given (%v)`, "hello", v, err)
+	}
+
+	if _, err := LookupCodec("application/x-unknown"); err == nil {
+		t.Errorf(`Unknown media type should return an error`)
+	}
+	req, _ = Post(ts.URL)
+	if _, err := req.SetBodyAs("application/x-unknown", "Hello").Do(); err == nil {
+		t.Errorf(`Do should return the error of an unknown media type`)
+	}
+	req, _ = Post(ts.URL)
+	if _, err := req.SetJSON(make(chan int)).Do(); err == nil {
+		t.Errorf(`Do should return the encode error`)
+	}
+}
@@ -0,0 +1,204 @@
+package okhttp
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// AcceptEncoding is the Accept-Encoding sent when the request has none, the response is decoded transparently
+const AcceptEncoding = "gzip, deflate, br, zstd"
+
+// acceptsCompression reports whether Do advertises and decodes the encodings, a request setting its own
+// Accept-Encoding or a Range gets the body as sent
+func (r *Request) acceptsCompression() bool {
+	return r.header.Get("Accept-Encoding") == "" && r.header.Get("Range") == ""
+}
+
+// SetCompressRequest compresses the request body with gzip, deflate, br or zstd and sets its Content-Encoding
+func (r *Request) SetCompressRequest(encoding string) *Request {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	switch encoding {
+	case "", "gzip", "deflate", "br", "zstd":
+		r.compressRequest = encoding
+		return r
+	}
+	return r.addError(fmt.Errorf("unsupported request encoding %q", encoding))
+}
+
+// compressBody compresses body with an encoding
+func compressBody(encoding string, body io.Reader) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(buf)
+	case "deflate":
+		w = zlib.NewWriter(buf)
+	case "br":
+		w = brotli.NewWriter(buf)
+	case "zstd":
+		zw, err := zstd.NewWriter(buf, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		w = zw
+	default:
+		return nil, fmt.Errorf("unsupported request encoding %q", encoding)
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		w.Close()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// countingReader counts the bytes read from the wire
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// decodedBody is a response body decoded from its Content-Encoding
+type decodedBody struct {
+	io.Reader
+	raw      io.ReadCloser
+	wire     *countingReader
+	encoding string
+	closers  []func()
+}
+
+func (b *decodedBody) Close() error {
+	for _, c := range b.closers {
+		c()
+	}
+	return b.raw.Close()
+}
+
+// decodeResponse replaces the body of a response with its decoded content,
+// the Content-Encoding and Content-Length headers are removed like net/http does for gzip
+func decodeResponse(response *http.Response) error {
+	encoding := response.Header.Get("Content-Encoding")
+	if encoding == "" || response.Body == nil || response.Body == http.NoBody {
+		return nil
+	}
+	var encodings []string
+	for _, e := range strings.Split(encoding, ",") {
+		if e = strings.ToLower(strings.TrimSpace(e)); e != "" && e != "identity" {
+			encodings = append(encodings, e)
+		}
+	}
+	for _, e := range encodings {
+		switch e {
+		case "gzip", "x-gzip", "deflate", "br", "zstd":
+		default:
+			// an unknown coding is left to the caller
+			return nil
+		}
+	}
+
+	wire := &countingReader{r: response.Body}
+	body := &decodedBody{raw: response.Body, wire: wire, encoding: encoding}
+	var r io.Reader = wire
+	for i := len(encodings) - 1; i >= 0; i-- {
+		r = &lazyDecoder{src: r, encoding: encodings[i], body: body}
+	}
+	body.Reader = r
+	response.Body = body
+	response.Header.Del("Content-Encoding")
+	response.Header.Del("Content-Length")
+	response.ContentLength = -1
+	response.Uncompressed = true
+	return nil
+}
+
+// lazyDecoder creates its decoder on the first read, an empty body like the one of HEAD stays empty
+type lazyDecoder struct {
+	src      io.Reader
+	encoding string
+	body     *decodedBody
+	r        io.Reader
+}
+
+func (d *lazyDecoder) Read(p []byte) (int, error) {
+	if d.r == nil {
+		br := bufio.NewReader(d.src)
+		head, err := br.Peek(2)
+		if len(head) == 0 {
+			if err == nil {
+				err = io.EOF
+			}
+			return 0, err
+		}
+		if d.r, err = d.newDecoder(br, head); err != nil {
+			return 0, fmt.Errorf("decode %s body: %w", d.encoding, err)
+		}
+	}
+	return d.r.Read(p)
+}
+
+func (d *lazyDecoder) newDecoder(r io.Reader, head []byte) (io.Reader, error) {
+	switch d.encoding {
+	case "gzip", "x-gzip":
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		d.body.closers = append(d.body.closers, func() { gr.Close() })
+		return gr, nil
+	case "deflate":
+		// deflate is zlib wrapped, but some servers send raw deflate
+		if len(head) == 2 && head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 {
+			zr, err := zlib.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			d.body.closers = append(d.body.closers, func() { zr.Close() })
+			return zr, nil
+		}
+		fr := flate.NewReader(r)
+		d.body.closers = append(d.body.closers, func() { fr.Close() })
+		return fr, nil
+	case "br":
+		return brotli.NewReader(r), nil
+	case "zstd":
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true))
+		if err != nil {
+			return nil, err
+		}
+		d.body.closers = append(d.body.closers, zr.Close)
+		return zr, nil
+	}
+	return nil, fmt.Errorf("unsupported encoding %q", d.encoding)
+}
+
+// ContentEncoding returns the Content-Encoding the body was sent with, "" when it was not encoded
+func (r *Response) ContentEncoding() string {
+	return r.contentEncoding
+}
+
+// CompressedSize returns the size of the body as sent, the length of the body when it was not encoded
+func (r *Response) CompressedSize() int64 {
+	if r.contentEncoding == "" {
+		return int64(len(r.body))
+	}
+	return r.compressedSize
+}
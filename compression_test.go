@@ -0,0 +1,109 @@
+package okhttp
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func Test_ResponseCompression(t *testing.T) {
+	payload := strings.Repeat(`{"foo":"compressed"}`, 100)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != AcceptEncoding {
+			t.Errorf(`Accept-Encoding should be "%s", "%s" given`, AcceptEncoding, r.Header.Get("Accept-Encoding"))
+		}
+		encoding := strings.TrimPrefix(r.URL.Path, "/")
+		if encoding == "identity" {
+			w.Write([]byte(payload))
+			return
+		}
+		body, err := compressBody(encoding, strings.NewReader(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Encoding", encoding)
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd", "identity"} {
+		req, _ := Get(ts.URL + "/" + encoding)
+		resp, err := req.Do()
+		if err != nil {
+			t.Fatalf(`%s: %v`, encoding, err)
+		}
+		if resp.String() != payload {
+			t.Errorf(`%s: body should be decoded, %d bytes given`, encoding, len(resp.GetBody()))
+		}
+		if encoding == "identity" {
+			if resp.ContentEncoding() != "" || resp.CompressedSize() != int64(len(payload)) {
+				t.Errorf(`identity: CompressedSize should be %d, %d given`, len(payload), resp.CompressedSize())
+			}
+			continue
+		}
+		if resp.ContentEncoding() != encoding {
+			t.Errorf(`ContentEncoding should be "%s", "%s" given`, encoding, resp.ContentEncoding())
+		}
+		if resp.CompressedSize() <= 0 || resp.CompressedSize() >= int64(len(payload)) {
+			t.Errorf(`%s: CompressedSize should be less than %d, %d given`, encoding, len(payload), resp.CompressedSize())
+		}
+		if resp.GetHeader("Content-Encoding") != "" {
+			t.Errorf(`%s: Content-Encoding should be removed`, encoding)
+		}
+	}
+
+	req, _ := Head(ts.URL + "/gzip")
+	if resp, err := req.Do(); err != nil || len(resp.GetBody()) != 0 {
+		t.Errorf(`HEAD with Content-Encoding should have an empty body, %v given`, err)
+	}
+}
+
+func Test_CompressRequest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader
+		switch r.Header.Get("Content-Encoding") {
+		case "gzip":
+			gr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = gr
+		case "zstd":
+			zr, _ := zstd.NewReader(r.Body)
+			defer zr.Close()
+			body = zr
+		case "br":
+			body = brotli.NewReader(r.Body)
+		default:
+			t.Errorf(`Content-Encoding should be set, "%s" given`, r.Header.Get("Content-Encoding"))
+			return
+		}
+		io.Copy(w, body)
+	}))
+	defer ts.Close()
+
+	for _, encoding := range []string{"gzip", "zstd", "br"} {
+		req, _ := Post(ts.URL)
+		resp, err := req.SetCompressRequest(encoding).SetJSON(testStruct{Foo: "foo", Fizz: 1}).Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.String() != `{"foo":"foo","bar":1}` {
+			t.Errorf(`%s: body should be "%s", "%s" given`, encoding, `{"foo":"foo","bar":1}`, resp.String())
+		}
+	}
+
+	req, _ := Post(ts.URL)
+	if req.SetCompressRequest("lzma").Err() == nil {
+		t.Errorf(`SetCompressRequest of an unknown encoding should be an error`)
+	}
+}
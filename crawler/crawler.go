@@ -0,0 +1,639 @@
+// Package crawler is a polite web crawler built on the okhttp client
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mredencom/okhttp"
+)
+
+// DefaultUserAgent is the user agent of the requests and the robots.txt group of a crawler
+const DefaultUserAgent = "okhttp-crawler/1.0"
+
+// maxSitemapDepth is the number of sitemap indexes followed from robots.txt
+const maxSitemapDepth = 2
+
+// Page is a fetched URL
+type Page struct {
+	URL      string
+	Depth    int
+	Response *okhttp.Response
+	// Links are the URLs found by the extractors, queued unless they were seen before
+	Links []string
+}
+
+// Extractor finds the URLs to crawl in a page
+type Extractor interface {
+	Extract(p *Page) ([]string, error)
+}
+
+// ExtractorFunc is a function used as Extractor
+type ExtractorFunc func(p *Page) ([]string, error)
+
+// Extract calls f
+func (f ExtractorFunc) Extract(p *Page) ([]string, error) { return f(p) }
+
+// Handler receives the fetched pages, it is called from several goroutines
+type Handler interface {
+	Handle(p *Page) error
+}
+
+// HandlerFunc is a function used as Handler
+type HandlerFunc func(p *Page) error
+
+// Handle calls f
+func (f HandlerFunc) Handle(p *Page) error { return f(p) }
+
+// LinkExtractor returns the links of HTML pages, rel="nofollow" links are skipped
+var LinkExtractor = ExtractorFunc(func(p *Page) ([]string, error) {
+	status := p.Response.GetStatus()
+	if status < http.StatusOK || status >= http.StatusMultipleChoices || !strings.Contains(p.Response.GetHeader("Content-Type"), "html") {
+		return nil, nil
+	}
+	doc, err := p.Response.HTML()
+	if err != nil {
+		return nil, err
+	}
+	var links []string
+	for _, l := range doc.Links() {
+		if !strings.Contains(" "+strings.ToLower(l.Rel)+" ", " nofollow ") {
+			links = append(links, l.URL)
+		}
+	}
+	return links, nil
+})
+
+// Crawler crawls the pages reachable from its seeds, it fetches robots.txt and waits between the requests to a host
+type Crawler struct {
+	client          *okhttp.Client
+	userAgent       string
+	seeds           []string
+	delay           time.Duration
+	concurrency     int
+	hostConcurrency int
+	maxPages        int
+	obeyRobots      bool
+	sitemaps        bool
+	extractors      []Extractor
+	handlers        []Handler
+	onError         func(u string, err error)
+	checkpointPath  string
+	checkpointEvery int
+
+	mu       sync.Mutex
+	frontier *frontier
+	hosts    map[string]*hostState
+	inFlight map[string]Task
+	pages    int
+	wake     chan struct{}
+}
+
+// hostState is the politeness state of a host
+type hostState struct {
+	robots *Robots
+	// loading is set until robots.txt is loaded, the pages of the host wait for it
+	loading bool
+	// loadingSitemaps is set until the sitemap URLs are queued, the crawl can't end before
+	loadingSitemaps bool
+	active          int
+	next            time.Time
+	// free is signaled when a request to the host ends
+	free chan struct{}
+}
+
+// New created a crawler with the client, a nil client uses the defaults
+func New(client *okhttp.Client) *Crawler {
+	if client == nil {
+		client = okhttp.NewClient()
+	}
+	return &Crawler{
+		client:          client,
+		userAgent:       DefaultUserAgent,
+		delay:           time.Second,
+		concurrency:     4,
+		hostConcurrency: 1,
+		obeyRobots:      true,
+		sitemaps:        true,
+		extractors:      []Extractor{LinkExtractor},
+		checkpointEvery: 10,
+		frontier:        newFrontier(),
+		hosts:           map[string]*hostState{},
+		inFlight:        map[string]Task{},
+		wake:            make(chan struct{}, 1),
+	}
+}
+
+// AddSeed add the URLs the crawl starts from
+func (c *Crawler) AddSeed(urls ...string) *Crawler {
+	c.seeds = append(c.seeds, urls...)
+	return c
+}
+
+// SetUserAgent set the user agent of the requests, its product token selects the robots.txt group
+func (c *Crawler) SetUserAgent(ua string) *Crawler {
+	c.userAgent = ua
+	return c
+}
+
+// SetMaxDepth set the number of links followed from a seed, negative is unlimited
+func (c *Crawler) SetMaxDepth(depth int) *Crawler {
+	c.frontier.maxDepth = depth
+	return c
+}
+
+// SetAllowedDomains limits the crawl to the domains and their subdomains
+func (c *Crawler) SetAllowedDomains(domains ...string) *Crawler {
+	for _, d := range domains {
+		c.frontier.domains = append(c.frontier.domains, strings.ToLower(d))
+	}
+	return c
+}
+
+// SetMaxPages stops the crawl after n pages, 0 is unlimited
+func (c *Crawler) SetMaxPages(n int) *Crawler {
+	c.maxPages = n
+	return c
+}
+
+// SetDelay set the minimum time between two requests to a host, a longer robots.txt crawl-delay wins
+func (c *Crawler) SetDelay(d time.Duration) *Crawler {
+	c.delay = d
+	return c
+}
+
+// SetConcurrency set the number of pages fetched at the same time
+func (c *Crawler) SetConcurrency(n int) *Crawler {
+	if n > 0 {
+		c.concurrency = n
+	}
+	return c
+}
+
+// SetHostConcurrency set the number of pages of a host fetched at the same time
+func (c *Crawler) SetHostConcurrency(n int) *Crawler {
+	if n > 0 {
+		c.hostConcurrency = n
+	}
+	return c
+}
+
+// SetObeyRobots set whether robots.txt is fetched and obeyed, default true
+func (c *Crawler) SetObeyRobots(obey bool) *Crawler {
+	c.obeyRobots = obey
+	return c
+}
+
+// SetSitemaps set whether the sitemaps of robots.txt and /sitemap.xml are crawled, default true
+func (c *Crawler) SetSitemaps(enabled bool) *Crawler {
+	c.sitemaps = enabled
+	return c
+}
+
+// SetExtractors replaces the link extractors, the default is LinkExtractor
+func (c *Crawler) SetExtractors(extractors ...Extractor) *Crawler {
+	c.extractors = extractors
+	return c
+}
+
+// AddExtractor add a link extractor
+func (c *Crawler) AddExtractor(e Extractor) *Crawler {
+	c.extractors = append(c.extractors, e)
+	return c
+}
+
+// AddHandler add a handler of the fetched pages
+func (c *Crawler) AddHandler(h Handler) *Crawler {
+	c.handlers = append(c.handlers, h)
+	return c
+}
+
+// OnPage add a function handling the fetched pages
+func (c *Crawler) OnPage(f func(p *Page) error) *Crawler {
+	return c.AddHandler(HandlerFunc(f))
+}
+
+// OnError set the function receiving the errors of fetches, extractors and handlers
+func (c *Crawler) OnError(f func(u string, err error)) *Crawler {
+	c.onError = f
+	return c
+}
+
+// SetCheckpoint saves the frontier to path every n pages and when the crawl stops,
+// Run resumes from the file when it exists
+func (c *Crawler) SetCheckpoint(path string, every int) *Crawler {
+	c.checkpointPath = path
+	if every > 0 {
+		c.checkpointEvery = every
+	}
+	return c
+}
+
+// Pages returns the number of pages fetched
+func (c *Crawler) Pages() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pages
+}
+
+// Run crawls until the frontier is empty, the max pages are fetched or ctx is done
+func (c *Crawler) Run(ctx context.Context) error {
+	if c.checkpointPath != "" {
+		cp, err := loadCheckpoint(c.checkpointPath)
+		if err != nil {
+			return err
+		}
+		if cp != nil {
+			c.mu.Lock()
+			for _, u := range cp.Seen {
+				c.frontier.seen[u] = true
+			}
+			c.frontier.queue = append(c.frontier.queue, cp.Queue...)
+			c.pages = cp.Pages
+			c.mu.Unlock()
+		}
+	}
+	c.mu.Lock()
+	for _, seed := range c.seeds {
+		c.frontier.push(seed, 0)
+	}
+	c.mu.Unlock()
+
+	// the sitemaps still loading are canceled when the crawl ends early
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	err := c.dispatch(ctx, &wg)
+	cancel()
+	wg.Wait()
+	if c.checkpointPath != "" {
+		if cpErr := c.saveCheckpoint(); cpErr != nil && err == nil {
+			err = cpErr
+		}
+	}
+	return err
+}
+
+// dispatch starts a fetch for every task whose host is ready, until there is no task left
+func (c *Crawler) dispatch(ctx context.Context, wg *sync.WaitGroup) error {
+	sem := make(chan struct{}, c.concurrency)
+	for {
+		c.mu.Lock()
+		if c.maxPages > 0 && c.pages+len(c.inFlight) >= c.maxPages {
+			done := len(c.inFlight) == 0
+			c.mu.Unlock()
+			if done {
+				return nil
+			}
+			if err := c.wait(ctx, 0); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(c.frontier.queue) == 0 && len(c.inFlight) == 0 && !c.loadingHosts() {
+			c.mu.Unlock()
+			return nil
+		}
+		task, wait := c.next(ctx, wg)
+		c.mu.Unlock()
+
+		if task == nil {
+			if err := c.wait(ctx, wait); err != nil {
+				return err
+			}
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			c.mu.Lock()
+			c.release(*task, false)
+			c.mu.Unlock()
+			return ctx.Err()
+		}
+		wg.Add(1)
+		go func(t Task) {
+			defer wg.Done()
+			c.fetch(ctx, t)
+			<-sem
+		}(*task)
+	}
+}
+
+// wait blocks until a fetch ends, a host becomes ready after d or ctx is done
+func (c *Crawler) wait(ctx context.Context, d time.Duration) error {
+	var timer <-chan time.Time
+	if d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		timer = t.C
+	}
+	select {
+	case <-c.wake:
+	case <-timer:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (c *Crawler) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// loadingHosts reports whether a host is loading its robots.txt or its sitemaps
+func (c *Crawler) loadingHosts() bool {
+	for _, h := range c.hosts {
+		if h.loading || h.loadingSitemaps {
+			return true
+		}
+	}
+	return false
+}
+
+// next removes the first task of a ready host from the queue, or returns how long to wait for one
+func (c *Crawler) next(ctx context.Context, wg *sync.WaitGroup) (*Task, time.Duration) {
+	now := time.Now()
+	wait := time.Duration(0)
+	for i := 0; i < len(c.frontier.queue); i++ {
+		t := c.frontier.queue[i]
+		u, err := url.Parse(t.URL)
+		if err != nil {
+			c.frontier.queue = append(c.frontier.queue[:i], c.frontier.queue[i+1:]...)
+			i--
+			continue
+		}
+		h := c.host(ctx, wg, u)
+		if h.loading || h.active >= c.hostConcurrency {
+			continue
+		}
+		if h.robots != nil && !h.robots.Allowed(u.RequestURI()) {
+			c.frontier.queue = append(c.frontier.queue[:i], c.frontier.queue[i+1:]...)
+			i--
+			continue
+		}
+		if d := h.next.Sub(now); d > 0 {
+			if wait == 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+
+		c.frontier.queue = append(c.frontier.queue[:i], c.frontier.queue[i+1:]...)
+		h.active++
+		h.next = now.Add(c.hostDelay(h))
+		c.inFlight[t.URL] = t
+		return &t, 0
+	}
+	return nil, wait
+}
+
+// host returns the state of the host of u, it starts to load robots.txt the first time
+func (c *Crawler) host(ctx context.Context, wg *sync.WaitGroup, u *url.URL) *hostState {
+	key := u.Scheme + "://" + u.Host
+	h, ok := c.hosts[key]
+	if ok {
+		return h
+	}
+	h = &hostState{free: make(chan struct{}, 1)}
+	c.hosts[key] = h
+	if !c.obeyRobots && !c.sitemaps {
+		return h
+	}
+	h.loading, h.loadingSitemaps = true, c.sitemaps
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		robots, sitemaps := c.loadRobots(ctx, h, key)
+		c.mu.Lock()
+		if c.obeyRobots {
+			h.robots = robots
+		}
+		h.loading = false
+		c.mu.Unlock()
+		c.signal()
+		if c.sitemaps {
+			c.loadSitemaps(ctx, h, sitemaps)
+			c.mu.Lock()
+			h.loadingSitemaps = false
+			c.mu.Unlock()
+			c.signal()
+		}
+	}()
+	return h
+}
+
+// hostDelay returns the time between two requests to a host, the longest of the delay and the crawl-delay
+func (c *Crawler) hostDelay(h *hostState) time.Duration {
+	if h.robots != nil && h.robots.CrawlDelay > c.delay {
+		return h.robots.CrawlDelay
+	}
+	return c.delay
+}
+
+// politeGet fetches a URL of a host once it's ready, like the pages it waits for the delay and a free slot
+func (c *Crawler) politeGet(ctx context.Context, h *hostState, u string) (*okhttp.Response, error) {
+	for {
+		c.mu.Lock()
+		wait := time.Until(h.next)
+		if h.active < c.hostConcurrency && wait <= 0 {
+			h.active++
+			h.next = time.Now().Add(c.hostDelay(h))
+			c.mu.Unlock()
+			break
+		}
+		c.mu.Unlock()
+		var timer *time.Timer
+		var ready <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			ready = timer.C
+		}
+		select {
+		case <-h.free:
+		case <-ready:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	resp, err := c.get(ctx, u)
+	c.mu.Lock()
+	h.active--
+	c.mu.Unlock()
+	h.signal()
+	c.signal()
+	return resp, err
+}
+
+func (h *hostState) signal() {
+	select {
+	case h.free <- struct{}{}:
+	default:
+	}
+}
+
+// loadRobots fetches the robots.txt of a host, it returns the rules and the sitemaps to crawl
+func (c *Crawler) loadRobots(ctx context.Context, h *hostState, origin string) (*Robots, []string) {
+	sitemaps := []string{origin + "/sitemap.xml"}
+	resp, err := c.politeGet(ctx, h, origin+"/robots.txt")
+	switch {
+	case err != nil:
+		c.error(origin+"/robots.txt", err)
+		return DisallowAll, nil
+	case resp.GetStatus() >= http.StatusInternalServerError:
+		return DisallowAll, nil
+	case resp.GetStatus() != http.StatusOK:
+		return AllowAll, sitemaps
+	}
+	robots, err := ParseRobots(strings.NewReader(resp.String()), c.userAgent)
+	if err != nil {
+		c.error(origin+"/robots.txt", err)
+		return AllowAll, sitemaps
+	}
+	if len(robots.Sitemaps) > 0 {
+		sitemaps = robots.Sitemaps
+	}
+	return robots, sitemaps
+}
+
+// loadSitemaps queues the URLs of the sitemaps as seeds
+func (c *Crawler) loadSitemaps(ctx context.Context, h *hostState, sitemaps []string) {
+	seen := map[string]bool{}
+	for depth := 0; depth <= maxSitemapDepth && len(sitemaps) > 0; depth++ {
+		var next []string
+		for _, u := range sitemaps {
+			if seen[u] {
+				continue
+			}
+			seen[u] = true
+			resp, err := c.politeGet(ctx, h, u)
+			if err != nil || resp.GetStatus() != http.StatusOK {
+				continue
+			}
+			sitemap, err := ParseSitemap([]byte(resp.String()))
+			if err != nil {
+				c.error(u, err)
+				continue
+			}
+			c.mu.Lock()
+			for _, page := range sitemap.URLs {
+				c.frontier.push(page, 0)
+			}
+			c.mu.Unlock()
+			next = append(next, sitemap.Sitemaps...)
+		}
+		sitemaps = next
+	}
+}
+
+func (c *Crawler) get(ctx context.Context, u string) (*okhttp.Response, error) {
+	req, err := c.client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := req.SetContext(ctx).SetUserAgent(c.userAgent).Do()
+	// a status rejected by the error policy of the client is still a page
+	var httpErr *okhttp.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Response, nil
+	}
+	return resp, err
+}
+
+// fetch fetches a task, extracts its links and calls the handlers
+func (c *Crawler) fetch(ctx context.Context, t Task) {
+	resp, err := c.get(ctx, t.URL)
+	if err != nil {
+		c.mu.Lock()
+		c.release(t, ctx.Err() == nil)
+		c.mu.Unlock()
+		if ctx.Err() == nil {
+			c.error(t.URL, err)
+		}
+		c.signal()
+		return
+	}
+
+	page := &Page{URL: t.URL, Depth: t.Depth, Response: resp}
+	for _, e := range c.extractors {
+		links, err := e.Extract(page)
+		if err != nil {
+			c.error(t.URL, err)
+			continue
+		}
+		page.Links = append(page.Links, links...)
+	}
+	c.mu.Lock()
+	for _, link := range page.Links {
+		c.frontier.push(link, t.Depth+1)
+	}
+	c.mu.Unlock()
+
+	for _, h := range c.handlers {
+		if err := h.Handle(page); err != nil {
+			c.error(t.URL, err)
+		}
+	}
+
+	c.mu.Lock()
+	c.release(t, true)
+	c.pages++
+	save := c.checkpointPath != "" && c.pages%c.checkpointEvery == 0
+	c.mu.Unlock()
+	if save {
+		if err := c.saveCheckpoint(); err != nil {
+			c.error(c.checkpointPath, err)
+		}
+	}
+	c.signal()
+}
+
+// release ends a task, a task which is not done goes back to the queue
+func (c *Crawler) release(t Task, done bool) {
+	delete(c.inFlight, t.URL)
+	if u, err := url.Parse(t.URL); err == nil {
+		if h := c.hosts[u.Scheme+"://"+u.Host]; h != nil && h.active > 0 {
+			h.active--
+			h.signal()
+		}
+	}
+	if !done {
+		c.frontier.queue = append([]Task{t}, c.frontier.queue...)
+	}
+}
+
+func (c *Crawler) error(u string, err error) {
+	if c.onError != nil && !errors.Is(err, context.Canceled) {
+		c.onError(u, err)
+	}
+}
+
+// saveCheckpoint saves the seen URLs and the queued and in-flight tasks
+func (c *Crawler) saveCheckpoint() error {
+	c.mu.Lock()
+	cp := &checkpoint{Pages: c.pages}
+	for u := range c.frontier.seen {
+		cp.Seen = append(cp.Seen, u)
+	}
+	sort.Strings(cp.Seen)
+	for _, t := range c.inFlight {
+		cp.Queue = append(cp.Queue, t)
+	}
+	sort.Slice(cp.Queue, func(i, j int) bool { return cp.Queue[i].URL < cp.Queue[j].URL })
+	cp.Queue = append(cp.Queue, c.frontier.queue...)
+	c.mu.Unlock()
+	return saveCheckpoint(c.checkpointPath, cp)
+}
@@ -0,0 +1,211 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newSite(t *testing.T) (*httptest.Server, *sync.Map) {
+	hits := &sync.Map{}
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := hits.LoadOrStore(r.URL.Path, new(int))
+		*n.(*int)++
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "User-agent: other\nDisallow: /\n\nUser-agent: *\nDisallow: /private\nAllow: /private/open$\nCrawl-delay: 0.01\nSitemap: %s/sitemap_index.xml\n", ts.URL)
+		case "/sitemap_index.xml":
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/sitemap.xml</loc></sitemap></sitemapindex>`, ts.URL)
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<urlset><url><loc>%s/orphan</loc></url></urlset>`, ts.URL)
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<a href="/a">a</a><a href="/b#top">b</a><a href="/private/x">x</a><a href="/private/open">open</a><a href="https://external.example/">ext</a><a href="/nofollow" rel="nofollow">n</a>`)
+		case "/a":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<a href="/">home</a><a href="/a/deep">deep</a>`)
+		case "/a/deep":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<a href="/a/deeper">deeper</a>`)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<p>leaf</p>`)
+		}
+	}))
+	return ts, hits
+}
+
+func crawled(c *Crawler, pages *[]string, mu *sync.Mutex) *Crawler {
+	return c.OnPage(func(p *Page) error {
+		mu.Lock()
+		*pages = append(*pages, strings.TrimPrefix(p.URL, p.Response.URL().Scheme+"://"+p.Response.URL().Host))
+		mu.Unlock()
+		return nil
+	})
+}
+
+func Test_Crawler(t *testing.T) {
+	ts, hits := newSite(t)
+	defer ts.Close()
+
+	var pages []string
+	var mu sync.Mutex
+	c := crawled(New(nil), &pages, &mu).
+		AddSeed(ts.URL).
+		SetDelay(10 * time.Millisecond).
+		SetMaxDepth(2).
+		SetAllowedDomains("127.0.0.1")
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(pages)
+	want := "/,/a,/a/deep,/b,/orphan,/private/open"
+	if strings.Join(pages, ",") != want {
+		t.Errorf(`pages should be "%s", "%s" given`, want, strings.Join(pages, ","))
+	}
+	if n, _ := hits.Load("/robots.txt"); *n.(*int) != 1 {
+		t.Errorf(`robots.txt should be fetched once, %d given`, *n.(*int))
+	}
+}
+
+func Test_CrawlerCheckpoint(t *testing.T) {
+	ts, hits := newSite(t)
+	defer ts.Close()
+	path := filepath.Join(t.TempDir(), "crawl.json")
+
+	var pages []string
+	var mu sync.Mutex
+	c := crawled(New(nil), &pages, &mu).AddSeed(ts.URL).SetDelay(0).SetAllowedDomains("127.0.0.1").SetMaxPages(2).SetCheckpoint(path, 1)
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf(`first run should fetch %d pages, %d given`, 2, len(pages))
+	}
+
+	c = crawled(New(nil), &pages, &mu).AddSeed(ts.URL).SetDelay(0).SetAllowedDomains("127.0.0.1").SetCheckpoint(path, 1)
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	hits.Range(func(k, v interface{}) bool {
+		if k != "/robots.txt" && !strings.HasPrefix(k.(string), "/sitemap") && *v.(*int) != 1 {
+			t.Errorf(`%s should be fetched once, %d given`, k, *v.(*int))
+		}
+		return true
+	})
+	if len(pages) != 7 {
+		t.Errorf(`both runs should fetch %d pages, %d given: %v`, 7, len(pages), pages)
+	}
+}
+
+func Test_CrawlerPoliteness(t *testing.T) {
+	var mu sync.Mutex
+	var last time.Time
+	var minGap time.Duration = time.Hour
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		if !last.IsZero() && time.Since(last) < minGap {
+			minGap = time.Since(last)
+		}
+		last = time.Now()
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<a href="/1">1</a><a href="/2">2</a><a href="/3">3</a>`)
+	}))
+	defer ts.Close()
+
+	c := New(nil).AddSeed(ts.URL).SetDelay(30 * time.Millisecond).SetConcurrency(4).SetSitemaps(false)
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if c.Pages() != 4 {
+		t.Errorf(`pages should be %d, %d given`, 4, c.Pages())
+	}
+	if minGap < 25*time.Millisecond {
+		t.Errorf(`requests to a host should wait %s, %s given`, 30*time.Millisecond, minGap)
+	}
+}
+
+func Test_CrawlerSlowSitemap(t *testing.T) {
+	var mu sync.Mutex
+	var last time.Time
+	var minGap time.Duration = time.Hour
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if !last.IsZero() && time.Since(last) < minGap {
+			minGap = time.Since(last)
+		}
+		last = time.Now()
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.WriteHeader(http.StatusNotFound)
+		case "/sitemap.xml":
+			time.Sleep(50 * time.Millisecond)
+			fmt.Fprintf(w, `<urlset><url><loc>%s/late</loc></url></urlset>`, ts.URL)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<p>leaf</p>`)
+		}
+	}))
+	defer ts.Close()
+
+	var pages []string
+	var pagesMu sync.Mutex
+	c := crawled(New(nil), &pages, &pagesMu).AddSeed(ts.URL + "/").SetDelay(30 * time.Millisecond).SetConcurrency(4)
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(pages)
+	if strings.Join(pages, ",") != "/,/late" {
+		t.Errorf(`pages should be "%s", "%s" given`, "/,/late", strings.Join(pages, ","))
+	}
+	if minGap < 25*time.Millisecond {
+		t.Errorf(`robots.txt and sitemaps should wait %s, %s given`, 30*time.Millisecond, minGap)
+	}
+}
+
+func Test_ParseRobots(t *testing.T) {
+	robots, err := ParseRobots(strings.NewReader(`
+User-agent: Googlebot
+User-agent: okhttp-crawler
+Disallow: /search
+Allow: /search/about
+Disallow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: *
+Disallow: /
+`), "okhttp-crawler/1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"/":                true,
+		"/search?q=go":     false,
+		"/search/about":    true,
+		"/files/a.pdf":     false,
+		"/files/a.pdf?x=1": true,
+	}
+	for path, want := range cases {
+		if robots.Allowed(path) != want {
+			t.Errorf(`%s should be allowed %v, %v given`, path, want, !want)
+		}
+	}
+	if robots.CrawlDelay != 2*time.Second {
+		t.Errorf(`CrawlDelay should be %s, %s given`, 2*time.Second, robots.CrawlDelay)
+	}
+}
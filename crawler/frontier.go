@@ -0,0 +1,84 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Task is an URL waiting in the frontier with the number of links followed from a seed
+type Task struct {
+	URL   string `json:"url"`
+	Depth int    `json:"depth"`
+}
+
+// frontier is the queue of URLs to crawl, an URL is queued once
+type frontier struct {
+	queue    []Task
+	seen     map[string]bool
+	maxDepth int
+	domains  []string
+}
+
+func newFrontier() *frontier {
+	return &frontier{seen: map[string]bool{}, maxDepth: -1}
+}
+
+// push queues an URL which is new, in the allowed domains and not deeper than the max depth
+func (f *frontier) push(rawURL string, depth int) bool {
+	u, ok := normalizeURL(rawURL)
+	if !ok || f.seen[u.String()] {
+		return false
+	}
+	if f.maxDepth >= 0 && depth > f.maxDepth {
+		return false
+	}
+	if !f.allowedDomain(u.Hostname()) {
+		return false
+	}
+	f.seen[u.String()] = true
+	f.queue = append(f.queue, Task{URL: u.String(), Depth: depth})
+	return true
+}
+
+// allowedDomain reports whether a host is one of the domains or a subdomain of them
+func (f *frontier) allowedDomain(host string) bool {
+	if len(f.domains) == 0 {
+		return true
+	}
+	for _, d := range f.domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeURL returns the canonical form of an http URL used to dedupe:
+// no fragment, lower case scheme and host, no default port and / for an empty path
+func normalizeURL(rawURL string) (*url.URL, bool) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, false
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, false
+	}
+	u.Fragment, u.RawFragment = "", ""
+	u.User = nil
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if u.Scheme == "http" && port == "80" || u.Scheme == "https" && port == "443" {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u.Host = host
+	if port != "" {
+		u.Host += ":" + port
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u, true
+}
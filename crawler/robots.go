@@ -0,0 +1,156 @@
+package crawler
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Robots are the rules of a robots.txt for a user agent
+type Robots struct {
+	rules []robotsRule
+	// CrawlDelay is the crawl-delay of the group of the user agent
+	CrawlDelay time.Duration
+	// Sitemaps are the sitemap URLs listed in the file
+	Sitemaps []string
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// AllowAll are the rules of a missing robots.txt
+var AllowAll = &Robots{}
+
+// DisallowAll are the rules of a robots.txt which could not be fetched because of a server error
+var DisallowAll = &Robots{rules: []robotsRule{{allow: false, pattern: "/"}}}
+
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// ParseRobots parses a robots.txt and keeps the group of userAgent, or the * group when none matches
+func ParseRobots(r io.Reader, userAgent string) (*Robots, error) {
+	token := strings.ToLower(userAgent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+
+	robots := &Robots{}
+	var groups []*robotsGroup
+	var current *robotsGroup
+	inAgents := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+				inAgents = true
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			continue
+		case "sitemap":
+			if value != "" {
+				robots.Sitemaps = append(robots.Sitemaps, value)
+			}
+		case "allow", "disallow":
+			// an empty disallow allows everything
+			if current != nil && value != "" {
+				current.rules = append(current.rules, robotsRule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			if current != nil {
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					current.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+		inAgents = false
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var matched, wildcard []*robotsGroup
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			switch {
+			case agent == "*":
+				wildcard = append(wildcard, g)
+			case token != "" && strings.Contains(token, agent):
+				matched = append(matched, g)
+			}
+		}
+	}
+	if len(matched) == 0 {
+		matched = wildcard
+	}
+	// the groups of the same agent are merged
+	for _, g := range matched {
+		robots.rules = append(robots.rules, g.rules...)
+		if g.crawlDelay > robots.CrawlDelay {
+			robots.CrawlDelay = g.crawlDelay
+		}
+	}
+	return robots, nil
+}
+
+// Allowed reports whether a path with its query may be fetched, the longest matching rule wins and allow wins a tie
+func (r *Robots) Allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	allowed, length := true, -1
+	for _, rule := range r.rules {
+		if !matchRobotsPattern(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > length || n == length && rule.allow {
+			allowed, length = rule.allow, n
+		}
+	}
+	return allowed
+}
+
+// matchRobotsPattern matches a path prefix pattern where * matches any sequence and a final $ anchors the end
+func matchRobotsPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 && anchored {
+			return strings.HasSuffix(path[pos:], part)
+		}
+		j := strings.Index(path[pos:], part)
+		if j < 0 {
+			return false
+		}
+		pos += j + len(part)
+	}
+	return !anchored || pos == len(path)
+}
@@ -0,0 +1,36 @@
+package crawler
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// Sitemap is a parsed sitemap.xml, a urlset lists pages and a sitemapindex lists other sitemaps
+type Sitemap struct {
+	URLs     []string
+	Sitemaps []string
+}
+
+// ParseSitemap parses a urlset or a sitemapindex
+func ParseSitemap(body []byte) (*Sitemap, error) {
+	var doc struct {
+		XMLName  xml.Name
+		URLs     []string `xml:"url>loc"`
+		Sitemaps []string `xml:"sitemap>loc"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	s := &Sitemap{}
+	for _, u := range doc.URLs {
+		if u = strings.TrimSpace(u); u != "" {
+			s.URLs = append(s.URLs, u)
+		}
+	}
+	for _, u := range doc.Sitemaps {
+		if u = strings.TrimSpace(u); u != "" {
+			s.Sitemaps = append(s.Sitemaps, u)
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,99 @@
+package okhttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DialContextFunc dials the connection of a request, it's the type of http.Transport.DialContext
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// defaultDialContext is used when no dialer is set
+var defaultDialContext = (&net.Dialer{
+	Timeout:   30 * time.Second,
+	KeepAlive: 30 * time.Second,
+}).DialContext
+
+// UnixDialer returns a dialer connecting every request to a unix socket
+// the address can be a path or an url like unix:///var/run/docker.sock
+func UnixDialer(address string) (DialContextFunc, error) {
+	path := strings.TrimPrefix(address, "unix://")
+	if path == "" || strings.Contains(path, "://") {
+		return nil, errors.New("illegal unix socket address " + address)
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", path)
+	}, nil
+}
+
+// PipeDialer serves a handler over in-memory net.Pipe connections,
+// requests dialed by it never touch a real socket
+type PipeDialer struct {
+	conns  chan net.Conn
+	done   chan struct{}
+	once   sync.Once
+	server *http.Server
+}
+
+// NewPipeDialer created a pipe dialer serving h
+func NewPipeDialer(h http.Handler) *PipeDialer {
+	d := &PipeDialer{
+		conns:  make(chan net.Conn),
+		done:   make(chan struct{}),
+		server: &http.Server{Handler: h},
+	}
+	go d.server.Serve(&pipeListener{d})
+	return d
+}
+
+// DialContext returns the client side of a new pipe
+func (d *PipeDialer) DialContext(ctx context.Context, _, _ string) (net.Conn, error) {
+	client, server := net.Pipe()
+	select {
+	case d.conns <- server:
+		return client, nil
+	case <-d.done:
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close stops serving and closes every open pipe
+func (d *PipeDialer) Close() error {
+	return d.server.Close()
+}
+
+// pipeListener hands the server side of the pipes to http.Server
+type pipeListener struct {
+	d *PipeDialer
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.d.conns:
+		return conn, nil
+	case <-l.d.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.d.once.Do(func() { close(l.d.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
@@ -0,0 +1,79 @@
+package okhttp
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_PipeDialer(t *testing.T) {
+	dialer := NewPipeDialer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pipe " + r.URL.Path))
+	}))
+	defer dialer.Close()
+
+	client := NewClient().SetDialContext(dialer.DialContext)
+	for i := 0; i < 2; i++ {
+		req, err := client.Get("http://agent.local/ping")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := req.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.String() != "pipe /ping" {
+			t.Errorf(`Response body should be "%s", "%s" given`, "pipe /ping", resp.String())
+		}
+	}
+}
+
+func Test_UnixSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skip("unix sockets are not supported: ", err)
+	}
+	defer os.Remove(sock)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("unix " + r.URL.Path))
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	req, _ := Get("http://docker/containers/json")
+	resp, err := req.SetUnixSocket("unix://" + sock).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.String() != "unix /containers/json" {
+		t.Errorf(`Response body should be "%s", "%s" given`, "unix /containers/json", resp.String())
+	}
+}
+
+func Test_UnixDialerAddress(t *testing.T) {
+	for _, address := range []string{"", "unix://", "tcp://127.0.0.1:80"} {
+		if _, err := UnixDialer(address); err == nil {
+			t.Errorf(`Address "%s" should be rejected`, address)
+		}
+	}
+}
+
+func Test_UnixSocketError(t *testing.T) {
+	req, err := NewRequest(http.MethodGet, "http://docker/containers/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := req.SetUnixSocket("tcp://127.0.0.1:80").Do(); err == nil {
+		t.Error("Do should return the error of SetUnixSocket")
+	}
+	req, err = NewClient().SetUnixSocket("").Get("http://docker/containers/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := req.Do(); err == nil {
+		t.Error("Do should return the error of Client.SetUnixSocket")
+	}
+}
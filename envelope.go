@@ -0,0 +1,146 @@
+package okhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/mredencom/okhttp/log"
+)
+
+// Envelope describes JSON bodies which wrap every payload like {"code":0,"msg":"ok","data":{...}},
+// the paths are dotted like "result.data"
+type Envelope struct {
+	CodePath    string
+	MessagePath string
+	DataPath    string
+	// SuccessCode is the code of a successful payload, other codes are returned as *BusinessError
+	SuccessCode int
+}
+
+// DefaultEnvelope is the {"code":0,"msg":"ok","data":{...}} envelope
+var DefaultEnvelope = &Envelope{CodePath: "code", MessagePath: "msg", DataPath: "data"}
+
+// BusinessError is returned when the code of an envelope is not the success code
+type BusinessError struct {
+	Code    int
+	Message string
+	// Data is the raw data of the envelope, often null
+	Data     json.RawMessage
+	Response *Response
+	// redacted is the message masked by the redactor of the request
+	redacted string
+}
+
+// Error returns the code and the message masked by the redactor of the request
+func (e *BusinessError) Error() string {
+	msg := e.redacted
+	if msg == "" {
+		msg = e.Message
+	}
+	return fmt.Sprintf("business error %d: %s", e.Code, msg)
+}
+
+// GetCode returns the business code
+func (e *BusinessError) GetCode() int {
+	return e.Code
+}
+
+// Trace returns the error traced at the caller by the log package, carrying the business code
+func (e *BusinessError) Trace(info ...interface{}) error {
+	return log.TraceEx(1, e, info...).SetCode(e.Code)
+}
+
+// unwrap returns the data of an envelope or the *BusinessError of its code
+func (e *Envelope) unwrap(resp *Response, body []byte) ([]byte, error) {
+	raw, ok := envelopeField(body, e.CodePath)
+	if !ok {
+		return nil, fmt.Errorf("envelope: no code at %q", e.CodePath)
+	}
+	code, err := strconv.Atoi(strings.Trim(string(raw), `"`))
+	if err != nil {
+		return nil, fmt.Errorf("envelope: code %s is not an int", raw)
+	}
+	data, _ := envelopeField(body, e.DataPath)
+	if code != e.SuccessCode {
+		be := &BusinessError{Code: code, Data: data, Response: resp}
+		be.Message = envelopeMessage(body, e.MessagePath)
+		be.redacted = be.Message
+		if resp != nil && resp.request != nil {
+			be.redacted = envelopeMessage(resp.request.getRedactor().maskJSON(body), e.MessagePath)
+		}
+		return nil, be
+	}
+	return data, nil
+}
+
+// envelopeMessage returns the message at a dotted path, the raw JSON when it's not a string
+func envelopeMessage(body []byte, path string) string {
+	raw, ok := envelopeField(body, path)
+	if !ok {
+		return ""
+	}
+	var msg string
+	if json.Unmarshal(raw, &msg) != nil {
+		return string(raw)
+	}
+	return msg
+}
+
+// envelopeField returns the raw JSON at a dotted path
+func envelopeField(body []byte, path string) (json.RawMessage, bool) {
+	raw := json.RawMessage(body)
+	if path == "" {
+		return raw, true
+	}
+	for _, name := range strings.Split(path, ".") {
+		fields := map[string]json.RawMessage{}
+		if json.Unmarshal(raw, &fields) != nil {
+			return nil, false
+		}
+		var ok bool
+		if raw, ok = fields[name]; !ok {
+			return nil, false
+		}
+	}
+	return raw, true
+}
+
+// isEnvelopeBody reports whether a body of contentType is unwrapped, only plain JSON bodies are
+func isEnvelopeBody(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json"
+}
+
+// SetEnvelope set the envelope unwrapped by GetJSON and Decode of every request, nil disables it
+func (c *Client) SetEnvelope(e *Envelope) *Client {
+	c.envelope = e
+	return c
+}
+
+// SetEnvelope set the envelope unwrapped by GetJSON and Decode, nil disables it
+func (r *Request) SetEnvelope(e *Envelope) *Request {
+	r.envelope = e
+	return r
+}
+
+// envelopeData returns the body to decode, the data of the envelope when the request has one
+func (r *Response) envelopeData(body []byte) ([]byte, error) {
+	if r.request == nil || r.request.envelope == nil || !isEnvelopeBody(r.GetHeader("Content-Type")) {
+		return body, nil
+	}
+	data, err := r.request.envelope.unwrap(r, body)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return []byte("null"), nil
+	}
+	return data, nil
+}
@@ -0,0 +1,61 @@
+package okhttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Envelope(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte(`{"code":0,"msg":"ok","data":{"foo":"foo","bar":7}}`))
+		case "/fail":
+			w.Write([]byte(`{"code":"1001","msg":"token expired","data":null}`))
+		case "/nested":
+			w.Write([]byte(`{"status":{"code":200,"message":"ok"},"result":{"data":{"foo":"nested"}}}`))
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient().SetEnvelope(DefaultEnvelope)
+	req, _ := client.Get(ts.URL + "/ok")
+	resp, err := req.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v testStruct
+	if err := resp.GetJSON(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Foo != "foo" || v.Fizz != 7 {
+		t.Errorf(`GetJSON should decode data, %+v given`, v)
+	}
+
+	req, _ = client.Get(ts.URL + "/fail")
+	_, _, err = DoJSON[testStruct](req)
+	var be *BusinessError
+	if !errors.As(err, &be) {
+		t.Fatalf(`DoJSON should return a *BusinessError, %v given`, err)
+	}
+	if be.GetCode() != 1001 || be.Message != "token expired" {
+		t.Errorf(`BusinessError should be "%s", "%s" given`, "business error 1001: token expired", be.Error())
+	}
+	if traced := be.Trace(); traced.(interface{ GetCode() int }).GetCode() != 1001 {
+		t.Errorf(`traced code should be %d, %d given`, 1001, traced.(interface{ GetCode() int }).GetCode())
+	}
+	var traced *BusinessError
+	if err := be.Trace("user", 1); !errors.As(err, &traced) || traced != be || !errors.Is(err, be) {
+		t.Errorf(`traced error should unwrap to the *BusinessError, %v given`, err)
+	}
+
+	req, _ = Get(ts.URL + "/nested")
+	req.SetEnvelope(&Envelope{CodePath: "status.code", MessagePath: "status.message", DataPath: "result.data", SuccessCode: 200})
+	v, _, err = DoAs[testStruct](req)
+	if err != nil || v.Foo != "nested" {
+		t.Errorf(`Foo should be "%s", "%s" given, %v`, "nested", v.Foo, err)
+	}
+}
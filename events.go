@@ -0,0 +1,349 @@
+package okhttp
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// EventListener receives the events of a call, like the EventListener of OkHttp,
+// the connection events are sent for every round trip of a call following redirects
+type EventListener interface {
+	CallStart(req *http.Request)
+	DNSStart(host string)
+	DNSEnd(addrs []net.IPAddr, err error)
+	ConnectStart(network, addr string)
+	ConnectEnd(network, addr string, err error)
+	SecureConnectStart()
+	SecureConnectEnd(state *tls.ConnectionState, err error)
+	ConnectionAcquired(info httptrace.GotConnInfo)
+	RequestHeadersEnd()
+	RequestBodyEnd(err error)
+	ResponseHeadersStart()
+	ResponseHeadersEnd(resp *http.Response)
+	ResponseBodyEnd(n int64)
+	CallEnd(timings Timings)
+	CallFailed(err error)
+}
+
+// EventListenerFactory creates the listener of a call, it's called once by every Do
+type EventListenerFactory func(r *Request) EventListener
+
+// NopEventListener ignores every event, embed it to implement only the events needed
+type NopEventListener struct{}
+
+func (NopEventListener) CallStart(*http.Request)                      {}
+func (NopEventListener) DNSStart(string)                              {}
+func (NopEventListener) DNSEnd([]net.IPAddr, error)                   {}
+func (NopEventListener) ConnectStart(string, string)                  {}
+func (NopEventListener) ConnectEnd(string, string, error)             {}
+func (NopEventListener) SecureConnectStart()                          {}
+func (NopEventListener) SecureConnectEnd(*tls.ConnectionState, error) {}
+func (NopEventListener) ConnectionAcquired(httptrace.GotConnInfo)     {}
+func (NopEventListener) RequestHeadersEnd()                           {}
+func (NopEventListener) RequestBodyEnd(error)                         {}
+func (NopEventListener) ResponseHeadersStart()                        {}
+func (NopEventListener) ResponseHeadersEnd(*http.Response)            {}
+func (NopEventListener) ResponseBodyEnd(int64)                        {}
+func (NopEventListener) CallEnd(Timings)                              {}
+func (NopEventListener) CallFailed(error)                             {}
+
+// Timings is the time spent in the phases of a call, the connection phases are the ones of its last round trip
+// and are zero when the connection was reused
+type Timings struct {
+	// DNS is the time resolving the host
+	DNS time.Duration
+	// Connect is the time opening the TCP connection
+	Connect time.Duration
+	// TLS is the time of the TLS handshake
+	TLS time.Duration
+	// TTFB is the time from the start of the call to the first byte of the response
+	TTFB time.Duration
+	// Transfer is the time from the first byte of the response to the end of its body
+	Transfer time.Duration
+	// Total is the time from the start to the end of the call
+	Total time.Duration
+	// Reused reports whether the last round trip used an idle connection
+	Reused bool
+}
+
+// AddEventListener add a factory creating a listener for every call of the client
+func (c *Client) AddEventListener(factory EventListenerFactory) *Client {
+	c.eventListeners = append(c.eventListeners, factory)
+	return c
+}
+
+// AddEventListener add a factory creating a listener for the calls of the request
+func (r *Request) AddEventListener(factory EventListenerFactory) *Request {
+	r.eventListeners = append(r.eventListeners, factory)
+	return r
+}
+
+// Timings returns the time spent in the phases of the call
+func (r *Response) Timings() Timings {
+	return r.timings
+}
+
+// eventListeners sends the events to every listener
+type eventListeners []EventListener
+
+func (ls eventListeners) CallStart(req *http.Request) {
+	for _, l := range ls {
+		l.CallStart(req)
+	}
+}
+
+func (ls eventListeners) DNSStart(host string) {
+	for _, l := range ls {
+		l.DNSStart(host)
+	}
+}
+
+func (ls eventListeners) DNSEnd(addrs []net.IPAddr, err error) {
+	for _, l := range ls {
+		l.DNSEnd(addrs, err)
+	}
+}
+
+func (ls eventListeners) ConnectStart(network, addr string) {
+	for _, l := range ls {
+		l.ConnectStart(network, addr)
+	}
+}
+
+func (ls eventListeners) ConnectEnd(network, addr string, err error) {
+	for _, l := range ls {
+		l.ConnectEnd(network, addr, err)
+	}
+}
+
+func (ls eventListeners) SecureConnectStart() {
+	for _, l := range ls {
+		l.SecureConnectStart()
+	}
+}
+
+func (ls eventListeners) SecureConnectEnd(state *tls.ConnectionState, err error) {
+	for _, l := range ls {
+		l.SecureConnectEnd(state, err)
+	}
+}
+
+func (ls eventListeners) ConnectionAcquired(info httptrace.GotConnInfo) {
+	for _, l := range ls {
+		l.ConnectionAcquired(info)
+	}
+}
+
+func (ls eventListeners) RequestHeadersEnd() {
+	for _, l := range ls {
+		l.RequestHeadersEnd()
+	}
+}
+
+func (ls eventListeners) RequestBodyEnd(err error) {
+	for _, l := range ls {
+		l.RequestBodyEnd(err)
+	}
+}
+
+func (ls eventListeners) ResponseHeadersStart() {
+	for _, l := range ls {
+		l.ResponseHeadersStart()
+	}
+}
+
+func (ls eventListeners) ResponseHeadersEnd(resp *http.Response) {
+	for _, l := range ls {
+		l.ResponseHeadersEnd(resp)
+	}
+}
+
+func (ls eventListeners) ResponseBodyEnd(n int64) {
+	for _, l := range ls {
+		l.ResponseBodyEnd(n)
+	}
+}
+
+func (ls eventListeners) CallEnd(timings Timings) {
+	for _, l := range ls {
+		l.CallEnd(timings)
+	}
+}
+
+func (ls eventListeners) CallFailed(err error) {
+	for _, l := range ls {
+		l.CallFailed(err)
+	}
+}
+
+// call tracks the events and the timings of one Do
+type call struct {
+	listener eventListeners
+	l        Logger
+	traceID  string
+
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsEnd       time.Time
+	connectStart time.Time
+	connectEnd   time.Time
+	tlsStart     time.Time
+	tlsEnd       time.Time
+	firstByte    time.Time
+	reused       bool
+	read         int64
+	bodyEnded    bool
+	ended        bool
+	timings      Timings
+}
+
+// newCall creates the listeners of the request for a new call
+func (r *Request) newCall() *call {
+	c := &call{start: time.Now()}
+	for _, factory := range r.eventListeners {
+		if l := factory(r); l != nil {
+			c.listener = append(c.listener, l)
+		}
+	}
+	return c
+}
+
+// trace returns the httptrace hooks sending the connection events
+func (c *call) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			// a new round trip, forget the connection of the previous one
+			c.mu.Lock()
+			c.dnsStart, c.dnsEnd = time.Time{}, time.Time{}
+			c.connectStart, c.connectEnd = time.Time{}, time.Time{}
+			c.tlsStart, c.tlsEnd = time.Time{}, time.Time{}
+			c.mu.Unlock()
+		},
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			c.mark(&c.dnsStart, false)
+			c.listener.DNSStart(info.Host)
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			c.mark(&c.dnsEnd, true)
+			c.listener.DNSEnd(info.Addrs, info.Err)
+		},
+		ConnectStart: func(network, addr string) {
+			// dual stack dialing starts several connections, the first start and the last end are kept
+			c.mark(&c.connectStart, false)
+			c.listener.ConnectStart(network, addr)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			c.mark(&c.connectEnd, true)
+			c.listener.ConnectEnd(network, addr, err)
+		},
+		TLSHandshakeStart: func() {
+			c.mark(&c.tlsStart, false)
+			c.listener.SecureConnectStart()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			c.mark(&c.tlsEnd, true)
+			c.listener.SecureConnectEnd(&state, err)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			c.mu.Lock()
+			c.reused = info.Reused
+			c.mu.Unlock()
+			c.listener.ConnectionAcquired(info)
+		},
+		WroteHeaders: func() {
+			c.listener.RequestHeadersEnd()
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			c.listener.RequestBodyEnd(info.Err)
+		},
+		GotFirstResponseByte: func() {
+			c.mark(&c.firstByte, true)
+			c.listener.ResponseHeadersStart()
+		},
+	}
+}
+
+// mark records the time of an event, only the first one unless last
+func (c *call) mark(t *time.Time, last bool) {
+	c.mu.Lock()
+	if last || t.IsZero() {
+		*t = time.Now()
+	}
+	c.mu.Unlock()
+}
+
+// bodyRead counts the bytes of the response body, its end is sent at io.EOF
+func (c *call) bodyRead(n int, err error) {
+	c.mu.Lock()
+	c.read += int64(n)
+	c.mu.Unlock()
+	if err == io.EOF {
+		c.endBody()
+	} else if err != nil {
+		c.fail(err)
+	}
+}
+
+func (c *call) endBody() {
+	c.mu.Lock()
+	if c.bodyEnded || c.ended {
+		c.mu.Unlock()
+		return
+	}
+	c.bodyEnded = true
+	n := c.read
+	c.mu.Unlock()
+	c.listener.ResponseBodyEnd(n)
+}
+
+// end ends the body and the call once, it returns the timings of the call
+func (c *call) end() Timings {
+	c.endBody()
+	c.mu.Lock()
+	if c.ended {
+		defer c.mu.Unlock()
+		return c.timings
+	}
+	c.ended = true
+	now := time.Now()
+	t := Timings{
+		DNS:     between(c.dnsStart, c.dnsEnd),
+		Connect: between(c.connectStart, c.connectEnd),
+		TLS:     between(c.tlsStart, c.tlsEnd),
+		TTFB:    between(c.start, c.firstByte),
+		Total:   now.Sub(c.start),
+		Reused:  c.reused,
+	}
+	if !c.firstByte.IsZero() {
+		t.Transfer = now.Sub(c.firstByte)
+	}
+	c.timings = t
+	c.mu.Unlock()
+	c.listener.CallEnd(t)
+	return t
+}
+
+// fail ends the call once with an error
+func (c *call) fail(err error) {
+	c.mu.Lock()
+	if c.ended {
+		c.mu.Unlock()
+		return
+	}
+	c.ended = true
+	c.mu.Unlock()
+	c.listener.CallFailed(err)
+}
+
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
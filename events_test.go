@@ -0,0 +1,84 @@
+package okhttp
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type eventRecorder struct {
+	NopEventListener
+	mu     sync.Mutex
+	events []string
+	read   int64
+}
+
+func (l *eventRecorder) add(e string) {
+	l.mu.Lock()
+	l.events = append(l.events, e)
+	l.mu.Unlock()
+}
+
+func (l *eventRecorder) CallStart(*http.Request)                      { l.add("callStart") }
+func (l *eventRecorder) ConnectStart(string, string)                  { l.add("connectStart") }
+func (l *eventRecorder) SecureConnectEnd(*tls.ConnectionState, error) { l.add("secureConnectEnd") }
+func (l *eventRecorder) ResponseHeadersStart()                        { l.add("responseHeadersStart") }
+func (l *eventRecorder) ResponseBodyEnd(n int64)                      { l.read = n; l.add("responseBodyEnd") }
+func (l *eventRecorder) CallEnd(Timings)                              { l.add("callEnd") }
+func (l *eventRecorder) CallFailed(error)                             { l.add("callFailed") }
+
+func Test_EventListener(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	var listeners []*eventRecorder
+	client := NewClient().SetTLSConfig(&tls.Config{InsecureSkipVerify: true}).
+		AddEventListener(func(r *Request) EventListener {
+			l := &eventRecorder{}
+			listeners = append(listeners, l)
+			return l
+		})
+
+	for i := 0; i < 2; i++ {
+		req, _ := client.Get(ts.URL)
+		resp, err := req.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		timings := resp.Timings()
+		if timings.Connect <= 0 || timings.TLS <= 0 || timings.TTFB <= 0 {
+			t.Errorf(`Connect, TLS and TTFB should be measured, %+v given`, timings)
+		}
+		if timings.Transfer < 10*time.Millisecond || timings.Total < timings.TTFB+timings.Transfer {
+			t.Errorf(`Transfer should include the slow body, %+v given`, timings)
+		}
+	}
+	if len(listeners) != 2 {
+		t.Fatalf(`a listener should be created for every call, %d given`, len(listeners))
+	}
+	want := "callStart connectStart secureConnectEnd responseHeadersStart responseBodyEnd callEnd"
+	if got := strings.Join(listeners[0].events, " "); got != want {
+		t.Errorf(`events should be "%s", "%s" given`, want, got)
+	}
+	if listeners[0].read != 5 {
+		t.Errorf(`ResponseBodyEnd should be 5 bytes, %d given`, listeners[0].read)
+	}
+
+	l := &eventRecorder{}
+	req, _ := Get("http://127.0.0.1:1")
+	if _, err := req.AddEventListener(func(*Request) EventListener { return l }).Do(); err == nil {
+		t.Fatalf(`Do of a closed port should fail`)
+	}
+	if got := strings.Join(l.events, " "); got != "callStart connectStart callFailed" {
+		t.Errorf(`events should be "callStart connectStart callFailed", "%s" given`, got)
+	}
+}
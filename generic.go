@@ -0,0 +1,98 @@
+package okhttp
+
+import (
+	"net/http"
+	"reflect"
+)
+
+// Result holds the decoded body of a request with its response and error
+type Result[T any] struct {
+	Value    T
+	Response *Response
+	Err      error
+}
+
+// Unwrap returns the value and the error
+func (r Result[T]) Unwrap() (T, error) {
+	return r.Value, r.Err
+}
+
+// Fetch sends the request and decodes the body into a Result
+func Fetch[T any](r *Request) Result[T] {
+	v, resp, err := DoAs[T](r)
+	return Result[T]{Value: v, Response: resp, Err: err}
+}
+
+// DoJSON sends the request and decodes the JSON body into T
+func DoJSON[T any](r *Request) (T, *Response, error) {
+	return do[T](r, (*Response).GetJSON)
+}
+
+// DoAs sends the request and decodes the body into T with the codec of its Content-Type
+func DoAs[T any](r *Request) (T, *Response, error) {
+	return do[T](r, (*Response).Decode)
+}
+
+func do[T any](r *Request, decode func(*Response, interface{}) error) (T, *Response, error) {
+	var v T
+	resp, err := r.Do()
+	if err != nil {
+		return v, resp, err
+	}
+	// an error body is never decoded into T, even without error policy
+	if ErrorStatus(resp.status) {
+		return v, resp, r.httpError(resp)
+	}
+	// a pointer T like *pb.Message is decoded into a new value, codecs like protobuf need the message itself
+	if t := reflect.TypeOf((*T)(nil)).Elem(); t.Kind() == reflect.Ptr {
+		p := reflect.New(t.Elem()).Interface()
+		if err := decode(resp, p); err != nil {
+			return v, resp, err
+		}
+		return p.(T), resp, nil
+	}
+	if err := decode(resp, &v); err != nil {
+		return v, resp, err
+	}
+	return v, resp, nil
+}
+
+// GetAs sends a get request with the client and decodes the body into T, a nil client uses the defaults
+func GetAs[T any](c *Client, uri string) (T, *Response, error) {
+	return sendAs[T](c, http.MethodGet, uri, "", nil)
+}
+
+// DeleteAs sends a delete request with the client and decodes the body into T
+func DeleteAs[T any](c *Client, uri string) (T, *Response, error) {
+	return sendAs[T](c, http.MethodDelete, uri, "", nil)
+}
+
+// PostAs sends body encoded as contentType with the client and decodes the response body into T
+func PostAs[T any](c *Client, uri, contentType string, body interface{}) (T, *Response, error) {
+	return sendAs[T](c, http.MethodPost, uri, contentType, body)
+}
+
+// PutAs sends body encoded as contentType with the client and decodes the response body into T
+func PutAs[T any](c *Client, uri, contentType string, body interface{}) (T, *Response, error) {
+	return sendAs[T](c, http.MethodPut, uri, contentType, body)
+}
+
+// PatchAs sends body encoded as contentType with the client and decodes the response body into T
+func PatchAs[T any](c *Client, uri, contentType string, body interface{}) (T, *Response, error) {
+	return sendAs[T](c, http.MethodPatch, uri, contentType, body)
+}
+
+func sendAs[T any](c *Client, method, uri, contentType string, body interface{}) (T, *Response, error) {
+	if c == nil {
+		c = NewClient()
+	}
+	r, err := c.NewRequest(method, uri)
+	if err != nil {
+		var v T
+		return v, nil, err
+	}
+	if contentType != "" {
+		r.SetBodyAs(contentType, body)
+	}
+	return DoAs[T](r)
+}
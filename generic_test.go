@@ -0,0 +1,69 @@
+package okhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func Test_DoJSON(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"foo":"bar","bar":42}`))
+	}))
+	defer ts.Close()
+
+	req, _ := Get(ts.URL)
+	v, resp, err := DoJSON[testStruct](req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetStatus() != http.StatusOK || v.Foo != "bar" || v.Fizz != 42 {
+		t.Errorf(`Should decode {bar 42}, %v given`, v)
+	}
+
+	req, _ = Get(ts.URL)
+	result := Fetch[map[string]interface{}](req)
+	if result.Err != nil || result.Value["foo"] != "bar" {
+		t.Errorf(`Result should hold the decoded body, %+v given`, result)
+	}
+}
+
+func Test_GetAs(t *testing.T) {
+	ts := newEchoServer(t)
+	defer ts.Close()
+
+	v, _, err := PostAs[codecStruct](NewClient(), ts.URL, "application/yaml", codecStruct{Foo: "bar", Fizz: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Foo != "bar" || v.Fizz != 7 {
+		t.Errorf(`Should decode {bar 7}, %v given`, v)
+	}
+
+	_, _, err = GetAs[codecStruct](nil, "::bad url")
+	if err == nil {
+		t.Errorf(`Bad url should return an error`)
+	}
+}
+
+func Test_DoAsProtobuf(t *testing.T) {
+	ts := newEchoServer(t)
+	defer ts.Close()
+
+	v, _, err := PostAs[*wrapperspb.StringValue](NewClient(), ts.URL, "application/x-protobuf", wrapperspb.String("okhttp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.GetValue() != "okhttp" {
+		t.Errorf(`Should be "%s", "%s" given`, "okhttp", v.GetValue())
+	}
+
+	req, _ := Post(ts.URL)
+	result := Fetch[*codecStruct](req.SetBodyAs("application/json", codecStruct{Foo: "bar", Fizz: 7}))
+	if result.Err != nil || result.Value == nil || result.Value.Foo != "bar" {
+		t.Errorf(`Result should hold a pointer to the decoded body, %+v given`, result)
+	}
+}
@@ -1,5 +1,19 @@
 module github.com/mredencom/okhttp
 
-go 1.17
+go 1.21
 
-require golang.org/x/net v0.0.0-20220325170049-de3da57026de
+require (
+	github.com/andybalholm/brotli v1.1.0
+	github.com/fxamacker/cbor/v2 v2.5.0
+	github.com/klauspost/compress v1.17.9
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	golang.org/x/net v0.0.0-20220325170049-de3da57026de
+	golang.org/x/text v0.3.7
+	google.golang.org/protobuf v1.31.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+)
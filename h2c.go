@@ -0,0 +1,246 @@
+package okhttp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// h2cUpgradeTransport sends every request over HTTP/1.1 asking the server to upgrade to h2c,
+// the response is read from stream 1 of the upgraded connection, or over HTTP/1.1 when
+// the server refuses. https requests are sent by tls.
+// Every request dials its own connection, closed with the body, so the connection is never idle:
+// the ReadIdleTimeout and PingTimeout health checks are not supported, MaxHeaderListSize is.
+type h2cUpgradeTransport struct {
+	dial    DialContextFunc
+	tls     http.RoundTripper
+	options *HTTP2Options
+}
+
+// h2cWindow is the flow control window granted to the server
+const h2cWindow = 1 << 20
+
+func (t *h2cUpgradeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" {
+		return t.tls.RoundTrip(req)
+	}
+
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		addr = net.JoinHostPort(req.URL.Hostname(), "80")
+	}
+	conn, err := t.dial(req.Context(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := []http2.Setting{{ID: http2.SettingInitialWindowSize, Val: h2cWindow}}
+	if t.options != nil && t.options.MaxHeaderListSize > 0 {
+		settings = append(settings, http2.Setting{ID: http2.SettingMaxHeaderListSize, Val: t.options.MaxHeaderListSize})
+	}
+	payload := &bytes.Buffer{}
+	for _, s := range settings {
+		payload.Write([]byte{byte(s.ID >> 8), byte(s.ID), byte(s.Val >> 24), byte(s.Val >> 16), byte(s.Val >> 8), byte(s.Val)})
+	}
+
+	upgrade := req.Clone(req.Context())
+	upgrade.Header.Set("Connection", "Upgrade, HTTP2-Settings")
+	upgrade.Header.Set("Upgrade", "h2c")
+	upgrade.Header.Set("HTTP2-Settings", base64.RawURLEncoding.EncodeToString(payload.Bytes()))
+	if err := upgrade.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	response, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		response.Body = &connBody{ReadCloser: response.Body, conn: conn}
+		return response, nil
+	}
+	response.Body.Close()
+
+	if _, err := io.WriteString(conn, http2.ClientPreface); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	framer := http2.NewFramer(conn, br)
+	framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	if t.options != nil {
+		framer.MaxHeaderListSize = t.options.MaxHeaderListSize
+	}
+	if err := framer.WriteSettings(settings...); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := framer.WriteWindowUpdate(0, h2cWindow-65535); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	stream := &h2cStream{conn: conn, framer: framer}
+	headers, err := stream.readHeaders()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	status, err := strconv.Atoi(headers.PseudoValue("status"))
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("h2c: bad status %q", headers.PseudoValue("status"))
+	}
+	header := http.Header{}
+	for _, f := range headers.RegularFields() {
+		header.Add(http.CanonicalHeaderKey(f.Name), f.Value)
+	}
+
+	pr, pw := io.Pipe()
+	stream.body = pw
+	if headers.StreamEnded() {
+		pw.Close()
+		conn.Close()
+	} else {
+		go stream.readBody()
+	}
+
+	contentLength := int64(-1)
+	if v, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+		contentLength = v
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/2.0",
+		ProtoMajor:    2,
+		Header:        header,
+		Body:          &connBody{ReadCloser: pr, conn: conn},
+		ContentLength: contentLength,
+		Request:       req,
+	}, nil
+}
+
+// h2cStream reads the response of stream 1 from an upgraded connection
+type h2cStream struct {
+	conn   net.Conn
+	framer *http2.Framer
+	body   *io.PipeWriter
+}
+
+// readHeaders returns the final response headers, skipping 1xx responses
+func (s *h2cStream) readHeaders() (*http2.MetaHeadersFrame, error) {
+	for {
+		f, err := s.framer.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch f := f.(type) {
+		case *http2.MetaHeadersFrame:
+			if f.StreamID != 1 {
+				continue
+			}
+			if strings.HasPrefix(f.PseudoValue("status"), "1") && !f.StreamEnded() {
+				continue
+			}
+			return f, nil
+		default:
+			if err := s.control(f); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// readBody copies the data of stream 1 to the body pipe until the stream ends
+func (s *h2cStream) readBody() {
+	defer s.conn.Close()
+	for {
+		f, err := s.framer.ReadFrame()
+		if err != nil {
+			s.body.CloseWithError(err)
+			return
+		}
+		switch f := f.(type) {
+		case *http2.DataFrame:
+			if f.StreamID != 1 {
+				continue
+			}
+			if _, err := s.body.Write(f.Data()); err != nil {
+				s.framer.WriteRSTStream(1, http2.ErrCodeCancel)
+				return
+			}
+			if n := uint32(f.Length); n > 0 {
+				s.framer.WriteWindowUpdate(0, n)
+				if !f.StreamEnded() {
+					s.framer.WriteWindowUpdate(1, n)
+				}
+			}
+			if f.StreamEnded() {
+				s.body.Close()
+				return
+			}
+		case *http2.MetaHeadersFrame:
+			// trailers
+			if f.StreamID == 1 && f.StreamEnded() {
+				s.body.Close()
+				return
+			}
+		default:
+			if err := s.control(f); err != nil {
+				s.body.CloseWithError(err)
+				return
+			}
+		}
+	}
+}
+
+// control handles the connection level frames
+func (s *h2cStream) control(f http2.Frame) error {
+	switch f := f.(type) {
+	case *http2.SettingsFrame:
+		if !f.IsAck() {
+			return s.framer.WriteSettingsAck()
+		}
+	case *http2.PingFrame:
+		if !f.IsAck() {
+			return s.framer.WritePing(true, f.Data)
+		}
+	case *http2.RSTStreamFrame:
+		if f.StreamID == 1 {
+			return http2.StreamError{StreamID: 1, Code: f.ErrCode}
+		}
+	case *http2.GoAwayFrame:
+		if f.ErrCode != http2.ErrCodeNo || f.LastStreamID < 1 {
+			return http2.ConnectionError(f.ErrCode)
+		}
+	}
+	return nil
+}
+
+// connBody closes the connection with the body
+type connBody struct {
+	io.ReadCloser
+	conn net.Conn
+}
+
+func (b *connBody) Close() error {
+	err := b.ReadCloser.Close()
+	if cerr := b.conn.Close(); err == nil && cerr != nil && !errors.Is(cerr, net.ErrClosed) {
+		err = cerr
+	}
+	return err
+}
@@ -1,6 +1,15 @@
 package okhttp
 
-import "net/http"
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // addHeaders 增加请求头
 func addHeaders(r *http.Request, headers http.Header) {
@@ -10,3 +19,244 @@ func addHeaders(r *http.Request, headers http.Header) {
 		}
 	}
 }
+
+// HeaderField is a header name with one of its values
+type HeaderField struct {
+	Name  string
+	Value string
+}
+
+// HeaderFields is an ordered view of headers, sorted by name and keeping the order of the values
+type HeaderFields []HeaderField
+
+// String returns the fields as header lines
+func (f HeaderFields) String() string {
+	b := strings.Builder{}
+	for _, field := range f {
+		b.WriteString(field.Name)
+		b.WriteString(": ")
+		b.WriteString(field.Value)
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
+
+// orderedHeaders returns the ordered view of headers
+func orderedHeaders(headers http.Header) HeaderFields {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make(HeaderFields, 0, len(names))
+	for _, name := range names {
+		for _, value := range headers[name] {
+			fields = append(fields, HeaderField{Name: name, Value: value})
+		}
+	}
+	return fields
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// encodeHeaders encodes a struct with tags like `header:"X-Request-Id,omitempty"` to headers,
+// slices add a value each, durations are seconds and times are HTTP-dates
+func encodeHeaders(v interface{}) (http.Header, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return http.Header{}, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("header encoder: %T is not a struct", v)
+	}
+	headers := http.Header{}
+	return headers, encodeHeaderStruct(headers, rv)
+}
+
+func encodeHeaderStruct(headers http.Header, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name, opts := parseTag(field.Tag.Get("header"))
+		fv := rv.Field(i)
+		if field.Anonymous && name == "" {
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && !isValuesScalar(fv.Type()) {
+				if err := encodeHeaderStruct(headers, fv); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if field.PkgPath != "" || name == "-" || opts.has("omitempty") && fv.IsZero() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+			if fv.IsNil() {
+				break
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+			continue
+		}
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 || fv.Kind() == reflect.Array {
+			for j := 0; j < fv.Len(); j++ {
+				s, err := formatHeaderValue(fv.Index(j))
+				if err != nil {
+					return fmt.Errorf("header encoder: %s: %w", name, err)
+				}
+				headers.Add(name, s)
+			}
+			continue
+		}
+		s, err := formatHeaderValue(fv)
+		if err != nil {
+			return fmt.Errorf("header encoder: %s: %w", name, err)
+		}
+		headers.Add(name, s)
+	}
+	return nil
+}
+
+func formatHeaderValue(rv reflect.Value) (string, error) {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return "", nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Type() {
+	case durationType:
+		d := rv.Interface().(time.Duration)
+		if d%time.Second == 0 {
+			return strconv.FormatInt(int64(d/time.Second), 10), nil
+		}
+		return d.String(), nil
+	case timeType:
+		return rv.Interface().(time.Time).UTC().Format(http.TimeFormat), nil
+	}
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+		return string(rv.Bytes()), nil
+	}
+	s, ok, err := valuesEncoder{tag: "header"}.scalar(rv, fieldOptions{})
+	if err == nil && !ok {
+		err = fmt.Errorf("unsupported type %s", rv.Type())
+	}
+	return s, err
+}
+
+// decodeHeaders decodes headers into a struct with tags like `header:"X-RateLimit-Remaining"`,
+// slices take every value split at commas, durations are seconds or like 1m30s and times are HTTP-dates
+func decodeHeaders(headers http.Header, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("header decoder: %T is not a pointer to a struct", v)
+	}
+	return decodeHeaderStruct(headers, rv.Elem())
+}
+
+func decodeHeaderStruct(headers http.Header, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name, _ := parseTag(field.Tag.Get("header"))
+		fv := rv.Field(i)
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !isValuesScalar(ft) && field.IsExported() {
+				if fv.Kind() == reflect.Ptr {
+					if fv.IsNil() {
+						fv.Set(reflect.New(ft))
+					}
+					fv = fv.Elem()
+				}
+				if err := decodeHeaderStruct(headers, fv); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if field.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		values := headers.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+
+		for fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+			items := reflect.MakeSlice(fv.Type(), 0, len(values))
+			for _, value := range values {
+				for _, part := range strings.Split(value, ",") {
+					item := reflect.New(fv.Type().Elem()).Elem()
+					if err := parseHeaderValue(item, strings.TrimSpace(part)); err != nil {
+						return fmt.Errorf("header decoder: %s: %w", name, err)
+					}
+					items = reflect.Append(items, item)
+				}
+			}
+			fv.Set(items)
+			continue
+		}
+		if err := parseHeaderValue(fv, strings.TrimSpace(values[0])); err != nil {
+			return fmt.Errorf("header decoder: %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func parseHeaderValue(rv reflect.Value, s string) error {
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Type() {
+	case durationType:
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			rv.SetInt(n * int64(time.Second))
+			return nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		rv.SetInt(int64(d))
+		return nil
+	case timeType:
+		t, err := http.ParseTime(s)
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if u, ok := rv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	return valuesDecoder{tag: "header"}.scalar(rv, s)
+}
@@ -0,0 +1,294 @@
+package okhttp
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Document is a parsed HTML document, relative URLs are resolved against its base URL
+type Document struct {
+	*Selection
+	root *html.Node
+	url  *url.URL
+	base *url.URL
+}
+
+// Selection is a list of elements of a document
+type Selection struct {
+	Nodes []*html.Node
+	doc   *Document
+	err   error
+}
+
+// Link is an <a href> or <area href> of a document
+type Link struct {
+	// URL is the absolute URL of the href
+	URL  string
+	Text string
+	Rel  string
+}
+
+// ParseHTML parses a UTF-8 HTML document, base is the URL it was fetched from
+func ParseHTML(body []byte, base *url.URL) (*Document, error) {
+	root, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	doc := &Document{root: root, url: base, base: base}
+	doc.Selection = &Selection{Nodes: []*html.Node{root}, doc: doc}
+
+	// <base href> changes the base of the relative URLs
+	if b := doc.Find("base[href]").First(); b.Length() > 0 {
+		href, _ := b.Attr("href")
+		if u, err := doc.resolve(href); err == nil {
+			doc.base = u
+		}
+	}
+	return doc, nil
+}
+
+// HTML parses the body transcoded to UTF-8 as an HTML document based on the final URL of the response
+func (r *Response) HTML() (*Document, error) {
+	r.htmlOnce.Do(func() {
+		body, err := r.text()
+		if err != nil {
+			r.htmlErr = err
+			return
+		}
+		r.html, r.htmlErr = ParseHTML(body, r.URL())
+	})
+	return r.html, r.htmlErr
+}
+
+// Root returns the document node
+func (d *Document) Root() *html.Node {
+	return d.root
+}
+
+// URL returns the URL the document was fetched from
+func (d *Document) URL() *url.URL {
+	return d.url
+}
+
+// Base returns the URL the relative URLs of the document are resolved against
+func (d *Document) Base() *url.URL {
+	return d.base
+}
+
+// Title returns the text of <title>
+func (d *Document) Title() string {
+	return strings.TrimSpace(d.Find("title").First().Text())
+}
+
+// Links returns the links of the document with absolute URLs, javascript: links are skipped
+func (d *Document) Links() []Link {
+	var links []Link
+	d.Find("a[href], area[href]").Each(func(_ int, s *Selection) {
+		href, _ := s.Attr("href")
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(href)), "javascript:") {
+			return
+		}
+		u, err := d.resolve(href)
+		if err != nil {
+			return
+		}
+		rel, _ := s.Attr("rel")
+		links = append(links, Link{URL: u.String(), Text: strings.Join(strings.Fields(s.Text()), " "), Rel: rel})
+	})
+	return links
+}
+
+// resolve returns the absolute URL of a reference
+func (d *Document) resolve(ref string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return nil, err
+	}
+	if d.base == nil {
+		return u, nil
+	}
+	return d.base.ResolveReference(u), nil
+}
+
+// Err returns the error of an invalid selector given to Find
+func (s *Selection) Err() error {
+	return s.err
+}
+
+// Find returns the descendants of the selection matching a CSS selector like "div.item > a[href]"
+func (s *Selection) Find(selector string) *Selection {
+	sel, err := CompileSelector(selector)
+	if err != nil {
+		return &Selection{doc: s.doc, err: err}
+	}
+	seen := map[*html.Node]bool{}
+	var nodes []*html.Node
+	for _, n := range s.Nodes {
+		for _, m := range sel.MatchAll(n) {
+			if !seen[m] {
+				seen[m] = true
+				nodes = append(nodes, m)
+			}
+		}
+	}
+	return &Selection{Nodes: nodes, doc: s.doc}
+}
+
+// Filter returns the elements of the selection matching a CSS selector
+func (s *Selection) Filter(selector string) *Selection {
+	sel, err := CompileSelector(selector)
+	if err != nil {
+		return &Selection{doc: s.doc, err: err}
+	}
+	var nodes []*html.Node
+	for _, n := range s.Nodes {
+		if sel.Match(n) {
+			nodes = append(nodes, n)
+		}
+	}
+	return &Selection{Nodes: nodes, doc: s.doc}
+}
+
+// Is reports whether an element of the selection matches a CSS selector
+func (s *Selection) Is(selector string) bool {
+	return s.Filter(selector).Length() > 0
+}
+
+// Length returns the number of elements
+func (s *Selection) Length() int {
+	return len(s.Nodes)
+}
+
+// Eq returns the element at index i, negative from the end
+func (s *Selection) Eq(i int) *Selection {
+	if i < 0 {
+		i += len(s.Nodes)
+	}
+	if i < 0 || i >= len(s.Nodes) {
+		return &Selection{doc: s.doc}
+	}
+	return &Selection{Nodes: s.Nodes[i : i+1], doc: s.doc}
+}
+
+// First returns the first element
+func (s *Selection) First() *Selection {
+	return s.Eq(0)
+}
+
+// Last returns the last element
+func (s *Selection) Last() *Selection {
+	return s.Eq(-1)
+}
+
+// Parent returns the parents of the elements
+func (s *Selection) Parent() *Selection {
+	seen := map[*html.Node]bool{}
+	var nodes []*html.Node
+	for _, n := range s.Nodes {
+		if p := n.Parent; p != nil && p.Type == html.ElementNode && !seen[p] {
+			seen[p] = true
+			nodes = append(nodes, p)
+		}
+	}
+	return &Selection{Nodes: nodes, doc: s.doc}
+}
+
+// Children returns the child elements
+func (s *Selection) Children() *Selection {
+	var nodes []*html.Node
+	for _, n := range s.Nodes {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode {
+				nodes = append(nodes, c)
+			}
+		}
+	}
+	return &Selection{Nodes: nodes, doc: s.doc}
+}
+
+// Each calls f with every element
+func (s *Selection) Each(f func(i int, s *Selection)) *Selection {
+	for i, n := range s.Nodes {
+		f(i, &Selection{Nodes: []*html.Node{n}, doc: s.doc})
+	}
+	return s
+}
+
+// Map returns the results of f for every element
+func (s *Selection) Map(f func(i int, s *Selection) string) []string {
+	out := make([]string, 0, len(s.Nodes))
+	s.Each(func(i int, e *Selection) {
+		out = append(out, f(i, e))
+	})
+	return out
+}
+
+// Text returns the text of the elements and their descendants, without script and style
+func (s *Selection) Text() string {
+	b := strings.Builder{}
+	for _, n := range s.Nodes {
+		b.WriteString(nodeText(n))
+	}
+	return b.String()
+}
+
+// Texts returns the trimmed text of every element
+func (s *Selection) Texts() []string {
+	return s.Map(func(_ int, e *Selection) string {
+		return strings.TrimSpace(e.Text())
+	})
+}
+
+// Attr returns an attribute of the first element
+func (s *Selection) Attr(name string) (string, bool) {
+	if len(s.Nodes) == 0 {
+		return "", false
+	}
+	return attr(s.Nodes[0], name)
+}
+
+// AttrOr returns an attribute of the first element, or def when it has none
+func (s *Selection) AttrOr(name, def string) string {
+	if v, ok := s.Attr(name); ok {
+		return v
+	}
+	return def
+}
+
+// AbsURL returns an URL attribute like href or src of the first element resolved against the document base
+func (s *Selection) AbsURL(name string) (string, bool) {
+	v, ok := s.Attr(name)
+	if !ok || s.doc == nil {
+		return v, ok
+	}
+	u, err := s.doc.resolve(v)
+	if err != nil {
+		return "", false
+	}
+	return u.String(), true
+}
+
+// HTML returns the inner HTML of the first element
+func (s *Selection) HTML() string {
+	if len(s.Nodes) == 0 {
+		return ""
+	}
+	b := bytes.Buffer{}
+	for c := s.Nodes[0].FirstChild; c != nil; c = c.NextSibling {
+		html.Render(&b, c)
+	}
+	return b.String()
+}
+
+// OuterHTML returns the HTML of the first element
+func (s *Selection) OuterHTML() string {
+	if len(s.Nodes) == 0 {
+		return ""
+	}
+	b := bytes.Buffer{}
+	html.Render(&b, s.Nodes[0])
+	return b.String()
+}
@@ -0,0 +1,312 @@
+package okhttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Form is a <form> of a document with the values its controls would submit
+type Form struct {
+	// Action is the absolute URL the form is submitted to, the document URL when it has no action
+	Action string
+	// Method is GET or POST
+	Method string
+	// Enctype is application/x-www-form-urlencoded, multipart/form-data or text/plain
+	Enctype string
+	Name    string
+	ID      string
+	// Fields are the named controls of the form in document order
+	Fields []FormField
+	// Values are the values of the successful controls, checked boxes and selected options only
+	Values url.Values
+
+	files   []formFile
+	referer string
+	page    *Response
+}
+
+// formFile is a file set on a multipart form
+type formFile struct {
+	field, filename string
+	content         io.Reader
+}
+
+// FormField is a named control of a form
+type FormField struct {
+	Name  string
+	Type  string
+	Value string
+	// Checked is set for checked boxes and radios and selected options
+	Checked  bool
+	Disabled bool
+}
+
+// Forms returns the forms of the document, controls outside a form are bound by their form attribute
+func (d *Document) Forms() []*Form {
+	var forms []*Form
+	byNode := map[*html.Node]*Form{}
+	byID := map[string]*Form{}
+	d.Find("form").Each(func(_ int, s *Selection) {
+		n := s.Nodes[0]
+		f := &Form{
+			Method:  strings.ToUpper(s.AttrOr("method", http.MethodGet)),
+			Enctype: strings.ToLower(s.AttrOr("enctype", "application/x-www-form-urlencoded")),
+			Name:    s.AttrOr("name", ""),
+			ID:      s.AttrOr("id", ""),
+			Values:  url.Values{},
+		}
+		if d.url != nil {
+			f.referer = d.url.String()
+		}
+		if f.Method != http.MethodPost {
+			f.Method = http.MethodGet
+		}
+		action, _ := s.Attr("action")
+		if u, err := d.resolve(action); err == nil {
+			f.Action = u.String()
+		}
+		forms = append(forms, f)
+		byNode[n] = f
+		if f.ID != "" {
+			byID[f.ID] = f
+		}
+	})
+
+	d.Find("input, select, textarea, button").Each(func(_ int, s *Selection) {
+		n := s.Nodes[0]
+		var f *Form
+		if id, ok := s.Attr("form"); ok {
+			f = byID[id]
+		} else {
+			for p := n.Parent; p != nil && f == nil; p = p.Parent {
+				f = byNode[p]
+			}
+		}
+		name, _ := s.Attr("name")
+		if f == nil || name == "" {
+			return
+		}
+		f.addControl(n, name)
+	})
+	return forms
+}
+
+func (f *Form) addControl(n *html.Node, name string) {
+	_, disabled := attr(n, "disabled")
+	switch n.Data {
+	case "select":
+		_, multiple := attr(n, "multiple")
+		options := (&Selection{Nodes: []*html.Node{n}}).Find("option").Nodes
+		selected := 0
+		for _, o := range options {
+			_, ok := attr(o, "selected")
+			value, hasValue := attr(o, "value")
+			if !hasValue {
+				value = strings.TrimSpace(nodeText(o))
+			}
+			f.Fields = append(f.Fields, FormField{Name: name, Type: "select", Value: value, Checked: ok, Disabled: disabled})
+			if ok && !disabled && (multiple || selected == 0) {
+				f.Values.Add(name, value)
+				selected++
+			}
+		}
+		// a single select without selected option submits its first option
+		if selected == 0 && !multiple && !disabled && len(options) > 0 {
+			f.Fields[len(f.Fields)-len(options)].Checked = true
+			f.Values.Add(name, f.Fields[len(f.Fields)-len(options)].Value)
+		}
+	case "textarea":
+		value := nodeText(n)
+		f.Fields = append(f.Fields, FormField{Name: name, Type: "textarea", Value: value, Disabled: disabled})
+		if !disabled {
+			f.Values.Add(name, value)
+		}
+	default:
+		typ, _ := attr(n, "type")
+		typ = strings.ToLower(typ)
+		if typ == "" {
+			typ = "text"
+			if n.Data == "button" {
+				typ = "submit"
+			}
+		}
+		value, hasValue := attr(n, "value")
+		_, checked := attr(n, "checked")
+		if !hasValue && (typ == "checkbox" || typ == "radio") {
+			value = "on"
+		}
+		f.Fields = append(f.Fields, FormField{Name: name, Type: typ, Value: value, Checked: checked, Disabled: disabled})
+		switch {
+		case disabled, typ == "submit", typ == "image", typ == "reset", typ == "button", typ == "file":
+		case typ == "checkbox" || typ == "radio":
+			if checked {
+				f.Values.Add(name, value)
+			}
+		default:
+			f.Values.Add(name, value)
+		}
+	}
+}
+
+// Forms returns the forms of an HTML response, they submit the cookies of the response
+func (r *Response) Forms() ([]*Form, error) {
+	doc, err := r.HTML()
+	if err != nil {
+		return nil, err
+	}
+	forms := doc.Forms()
+	for _, f := range forms {
+		f.page = r
+	}
+	return forms, nil
+}
+
+// Set replaces the values of a field
+func (f *Form) Set(name, value string) *Form {
+	f.Values.Set(name, value)
+	return f
+}
+
+// Add adds a value to a field
+func (f *Form) Add(name, value string) *Form {
+	f.Values.Add(name, value)
+	return f
+}
+
+// Del removes the values of a field
+func (f *Form) Del(name string) *Form {
+	f.Values.Del(name)
+	return f
+}
+
+// SetFile sets the file of a field, it is sent when the form is multipart/form-data
+func (f *Form) SetFile(field, filename string, content io.Reader) *Form {
+	for i := range f.files {
+		if f.files[i].field == field {
+			f.files[i] = formFile{field, filename, content}
+			return f
+		}
+	}
+	f.files = append(f.files, formFile{field, filename, content})
+	return f
+}
+
+// Submit builds the request of the form with the client, a nil client uses the defaults.
+// GET forms replace the query of the action, POST forms encode the values as their enctype.
+// The page URL is sent as referer, with the cookies of the page matching the action when the client has no cookie jar
+func (f *Form) Submit(c *Client) (*Request, error) {
+	if c == nil {
+		c = NewClient()
+	}
+	action, err := url.Parse(f.Action)
+	if err != nil {
+		return nil, err
+	}
+	if f.Method == http.MethodGet {
+		action.RawQuery = f.Values.Encode()
+	}
+	r, err := c.NewRequest(f.Method, action.String())
+	if err != nil {
+		return nil, err
+	}
+	if f.referer != "" {
+		r.SetReferer(f.referer)
+	}
+	if c.jar == nil && f.page != nil {
+		cookies, err := f.pageCookies(action)
+		if err != nil {
+			return nil, err
+		}
+		for _, cookie := range cookies {
+			r.SetCookie(cookie)
+		}
+	}
+	if f.Method == http.MethodGet {
+		return r, nil
+	}
+
+	switch f.Enctype {
+	case "multipart/form-data":
+		body, contentType, err := f.multipart()
+		if err != nil {
+			return nil, err
+		}
+		r.SetHeader("Content-Type", contentType)
+		r.SetBody(body)
+	case "text/plain":
+		b := strings.Builder{}
+		for _, name := range sortedKeys(f.Values) {
+			for _, v := range f.Values[name] {
+				fmt.Fprintf(&b, "%s=%s\r\n", name, v)
+			}
+		}
+		r.SetHeader("Content-Type", "text/plain")
+		r.SetBody(strings.NewReader(b.String()))
+	default:
+		r.SetForm(f.Values)
+	}
+	return r, nil
+}
+
+// pageCookies returns the cookies of the page matching the action, by the domain, path and secure rules of a jar
+func (f *Form) pageCookies(action *url.URL) ([]*http.Cookie, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+	if req := f.page.request; req != nil && req.url != nil {
+		jar.SetCookies(req.url, req.cookies)
+	}
+	page := f.page.url
+	if page == nil && f.page.request != nil {
+		page = f.page.request.url
+	}
+	if page != nil {
+		jar.SetCookies(page, f.page.GetCookies())
+	}
+	return jar.Cookies(action), nil
+}
+
+func (f *Form) multipart() (io.Reader, string, error) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	for _, name := range sortedKeys(f.Values) {
+		for _, v := range f.Values[name] {
+			if err := w.WriteField(name, v); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	for _, file := range f.files {
+		part, err := w.CreateFormFile(file.field, file.filename)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := io.Copy(part, file.content); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return body, w.FormDataContentType(), nil
+}
+
+func sortedKeys(v url.Values) []string {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,133 @@
+package okhttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const loginPage = `<html><body>
+<form id="login" method="post" action="login">
+	<input type="hidden" name="csrf" value="token-1">
+	<input name="user">
+	<input type="password" name="password">
+	<input type="checkbox" name="remember" value="yes" checked>
+	<select name="lang"><option value="en">English</option><option value="zh" selected>中文</option></select>
+	<textarea name="note">hi</textarea>
+	<input type="submit" name="go" value="Login">
+	<input name="off" value="x" disabled>
+</form>
+<form action="/upload" method="POST" enctype="multipart/form-data"><input type="file" name="doc"><input name="title" value="t"></form>
+</body></html>`
+
+func Test_FormSubmit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/account/":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(loginPage))
+		case "/account/login":
+			c, err := r.Cookie("session")
+			if err != nil || c.Value != "s1" {
+				t.Errorf(`session cookie should be "%s", %v given`, "s1", err)
+			}
+			r.ParseForm()
+			w.Write([]byte(r.PostForm.Encode() + " " + r.Referer()))
+		case "/upload":
+			f, h, err := r.FormFile("doc")
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := io.ReadAll(f)
+			w.Write([]byte(h.Filename + ":" + string(b) + ":" + r.FormValue("title")))
+		}
+	}))
+	defer ts.Close()
+
+	for name, client := range map[string]*Client{"jar": NewClient().EnableCookieJar(), "no jar": NewClient()} {
+		req, _ := client.Get(ts.URL + "/account/")
+		resp, err := req.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		forms, err := resp.Forms()
+		if err != nil || len(forms) != 2 {
+			t.Fatalf(`%s: Forms should be 2, %d given, %v`, name, len(forms), err)
+		}
+		login := forms[0]
+		if login.ID != "login" || login.Method != http.MethodPost || login.Action != ts.URL+"/account/login" {
+			t.Errorf(`%s: login form should post to "%s", %+v given`, name, ts.URL+"/account/login", login)
+		}
+
+		submit, err := login.Set("user", "me").Set("password", "secret").Submit(client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err = submit.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "csrf=token-1&lang=zh&note=hi&password=secret&remember=yes&user=me " + ts.URL + "/account/"
+		if resp.String() != want {
+			t.Errorf(`%s: submitted form should be "%s", "%s" given`, name, want, resp.String())
+		}
+	}
+
+	req, _ := Get(ts.URL + "/account/")
+	resp, _ := req.Do()
+	forms, _ := resp.Forms()
+	submit, err := forms[1].SetFile("doc", "a.txt", strings.NewReader("content")).Submit(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = submit.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.String() != "a.txt:content:t" {
+		t.Errorf(`upload should be "%s", "%s" given`, "a.txt:content:t", resp.String())
+	}
+}
+
+func Test_FormSubmitCookieScope(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/account/" {
+			http.SetCookie(w, &http.Cookie{Name: "account", Value: "a", Path: "/account"})
+			http.SetCookie(w, &http.Cookie{Name: "other", Value: "o", Path: "/other"})
+			w.Header().Set("Content-Type", "text/html")
+			other := strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+			w.Write([]byte(`<form method="post" action="login"></form><form method="post" action="` + other + `/account/login"></form>`))
+			return
+		}
+		var names []string
+		for _, c := range r.Cookies() {
+			names = append(names, c.Name)
+		}
+		w.Write([]byte(strings.Join(names, ",")))
+	}))
+	defer ts.Close()
+
+	req, _ := Get(ts.URL + "/account/")
+	resp, err := req.SetCookie(&http.Cookie{Name: "sent", Value: "x"}).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	forms, _ := resp.Forms()
+	for i, want := range []string{"sent,account", ""} {
+		submit, err := forms[i].Submit(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := submit.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.String() != want {
+			t.Errorf(`form %d should send the cookies "%s", "%s" given`, i, want, resp.String())
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package okhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const htmlPage = `<!DOCTYPE html>
+<html><head><title> Items </title></head>
+<body>
+<div id="main" class="list items">
+	<div class="item"><a href="/item/1" rel="next">One</a><span class="price">1.00</span></div>
+	<div class="item sold"><a href="item/2">Two</a><span class="price">2.00</span></div>
+	<div class="item"><a>No link</a><span class="price" data-currency="EUR">3.00</span></div>
+	<p>after</p>
+</div>
+<ul><li>a</li><li>b</li><li>c</li><li>d</li></ul>
+<a href="javascript:void(0)">js</a>
+<a href="https://other.example/x">other</a>
+<form id="search" action="/search"><input name="q" value="go"><input type="checkbox" name="new"></form>
+</body></html>`
+
+func Test_HTML(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/shop/list", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(htmlPage))
+	}))
+	defer ts.Close()
+
+	req, _ := Get(ts.URL + "/redirect")
+	resp, err := req.SetRedirects(true).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.URL().Path != "/shop/list" {
+		t.Errorf(`URL should be "%s", "%s" given`, "/shop/list", resp.URL().Path)
+	}
+	doc, err := resp.HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title() != "Items" {
+		t.Errorf(`Title should be "%s", "%s" given`, "Items", doc.Title())
+	}
+
+	cases := map[string]string{
+		"div.item > a[href]":                "One,Two",
+		"#main .item:not(.sold) .price":     "1.00,3.00",
+		"span[data-currency^=E]":            "3.00",
+		"div.item:has(a[href]) span":        "1.00,2.00",
+		"li:nth-child(odd)":                 "a,c",
+		"li:nth-last-child(-n+2)":           "c,d",
+		"li:first-child, li:last-child":     "a,d",
+		"div.item + p":                      "after",
+		"div.sold ~ div span":               "3.00",
+		"div.item:contains('Two') .price":   "2.00",
+		"DIV.item > A[HREF=\"/ITEM/1\" i]":  "One",
+		"a[rel~=next]":                      "One",
+		"ul > li:nth-of-type(2):only-child": "",
+	}
+	for selector, want := range cases {
+		s := doc.Find(selector)
+		if s.Err() != nil {
+			t.Errorf(`%s should have no error, %v given`, selector, s.Err())
+			continue
+		}
+		if got := strings.Join(s.Texts(), ","); got != want {
+			t.Errorf(`%s should be "%s", "%s" given`, selector, want, got)
+		}
+	}
+	for _, selector := range []string{"div[", "a::before", ":unknown", "div >"} {
+		if doc.Find(selector).Err() == nil {
+			t.Errorf(`%s should be an error`, selector)
+		}
+	}
+
+	href, _ := doc.Find(".sold a").AbsURL("href")
+	if href != ts.URL+"/shop/item/2" {
+		t.Errorf(`AbsURL should be "%s", "%s" given`, ts.URL+"/shop/item/2", href)
+	}
+	links := doc.Links()
+	if len(links) != 3 || links[0].URL != ts.URL+"/item/1" || links[0].Rel != "next" || links[2].URL != "https://other.example/x" {
+		t.Errorf(`Links should be 3 absolute links, %+v given`, links)
+	}
+
+	forms := doc.Forms()
+	if len(forms) != 1 || forms[0].Action != ts.URL+"/search" || forms[0].Method != http.MethodGet || forms[0].Values.Encode() != "q=go" {
+		t.Errorf(`Forms should be the search form, %+v given`, forms)
+	}
+}
@@ -0,0 +1,179 @@
+package okhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// errorBodySnippet is the size of the body kept by HTTPError
+const errorBodySnippet = 1024
+
+// StatusPolicy decides which response statuses Do returns as *HTTPError
+type StatusPolicy func(status int) bool
+
+// ErrorStatus treats 4xx and 5xx statuses as errors
+func ErrorStatus(status int) bool {
+	return status >= http.StatusBadRequest
+}
+
+// NonSuccessStatus treats every status outside 2xx as an error
+func NonSuccessStatus(status int) bool {
+	return status < http.StatusOK || status >= http.StatusMultipleChoices
+}
+
+// HTTPError is returned by Do for a status rejected by the status policy, the response is returned with it
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	// Body is the beginning of the response body
+	Body     []byte
+	Request  *Request
+	Response *Response
+	// Problem is set when the body is an RFC 9457 problem details document
+	Problem *ProblemDetails
+	// Result is the value set by SetErrorResult, decoded from the body
+	Result interface{}
+}
+
+// Error returns the method, url, status and the problem or body snippet, masked by the redactor of the request,
+// an error without request is masked by the default redactor
+func (e *HTTPError) Error() string {
+	redactor := defaultRedactor
+	msg := e.Status
+	if msg == "" {
+		msg = strings.TrimSpace(fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)))
+	}
+	if e.Request != nil {
+		redactor = e.Request.getRedactor()
+		msg = fmt.Sprintf("%s %s: %s", e.Request.Method(), redactor.URL(e.Request.url), msg)
+	}
+	body := e.Body
+	if e.Response != nil {
+		body = e.Response.body
+	}
+	problem := e.Problem
+	if problem != nil {
+		masked := &ProblemDetails{}
+		if json.Unmarshal(redactor.maskJSON(body), masked) == nil {
+			problem = masked
+		}
+	}
+	switch {
+	case problem != nil && problem.Detail != "":
+		msg += ": " + problem.Title + ": " + problem.Detail
+	case problem != nil && problem.Title != "":
+		msg += ": " + problem.Title
+	case len(body) > 0:
+		snippet := redactor.Body(e.Header.Get("Content-Type"), body)
+		if len(snippet) > errorBodySnippet {
+			snippet = snippet[:errorBodySnippet]
+		}
+		msg += ": " + strings.TrimSpace(string(snippet))
+	}
+	return msg
+}
+
+// Decode unmarshal the whole error body with the codec of its Content-Type
+func (e *HTTPError) Decode(v interface{}) error {
+	if e.Response == nil {
+		return errors.New("http error has no response to decode")
+	}
+	return e.Response.decode(v, false)
+}
+
+// ProblemDetails is an RFC 9457 problem details document
+type ProblemDetails struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Extensions holds the members which are not defined by the RFC
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// UnmarshalJSON collects the extension members
+func (p *ProblemDetails) UnmarshalJSON(data []byte) error {
+	type plain ProblemDetails
+	if err := json.Unmarshal(data, (*plain)(p)); err != nil {
+		return err
+	}
+	members := map[string]interface{}{}
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	for _, k := range []string{"type", "title", "status", "detail", "instance"} {
+		delete(members, k)
+	}
+	if len(members) > 0 {
+		p.Extensions = members
+	}
+	return nil
+}
+
+// SetErrorPolicy set the statuses returned as *HTTPError by every request, nil disables it
+func (c *Client) SetErrorPolicy(p StatusPolicy) *Client {
+	c.errorPolicy = p
+	return c
+}
+
+// SetErrorResult decodes the body of every *HTTPError into a new value of the type of v
+func (c *Client) SetErrorResult(v interface{}) *Client {
+	c.errorResult = reflect.TypeOf(v)
+	if c.errorResult != nil && c.errorResult.Kind() == reflect.Ptr {
+		c.errorResult = c.errorResult.Elem()
+	}
+	return c
+}
+
+// SetErrorPolicy set the statuses returned as *HTTPError by Do, nil disables it
+func (r *Request) SetErrorPolicy(p StatusPolicy) *Request {
+	r.errorPolicy = p
+	return r
+}
+
+// SetErrorResult decodes the body of an *HTTPError into v, it also enables ErrorStatus when no policy is set
+func (r *Request) SetErrorResult(v interface{}) *Request {
+	r.errorResult = v
+	if r.errorPolicy == nil {
+		r.errorPolicy = ErrorStatus
+	}
+	return r
+}
+
+// httpError returns the *HTTPError of a response rejected by the status policy
+func (r *Request) httpError(resp *Response) *HTTPError {
+	e := &HTTPError{
+		StatusCode: resp.status,
+		Status:     resp.statusText,
+		Header:     resp.headers,
+		Body:       resp.body,
+		Request:    r,
+		Response:   resp,
+	}
+	if len(e.Body) > errorBodySnippet {
+		e.Body = e.Body[:errorBodySnippet]
+	}
+	if e.Status == "" {
+		e.Status = fmt.Sprintf("%d %s", resp.status, http.StatusText(resp.status))
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(resp.GetHeader("Content-Type")); mediaType == "application/problem+json" {
+		problem := &ProblemDetails{}
+		if json.Unmarshal(resp.body, problem) == nil {
+			e.Problem = problem
+		}
+	}
+	if r.errorResult != nil && len(resp.body) > 0 {
+		if resp.decode(r.errorResult, false) == nil {
+			e.Result = r.errorResult
+		}
+	}
+	return e
+}
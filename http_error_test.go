@@ -0,0 +1,103 @@
+package okhttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func Test_HTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/problem":
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"type":"https://example.com/probs/credit","title":"No credit","status":403,"detail":"balance is 30","balance":30}`))
+		case "/api":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"not_found","message":"no such user"}`))
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient().SetErrorPolicy(ErrorStatus)
+
+	req, _ := client.Get(ts.URL + "/problem")
+	resp, err := req.Do()
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf(`Error should be *HTTPError, %v given`, err)
+	}
+	if resp == nil || httpErr.StatusCode != http.StatusForbidden || httpErr.Request != req {
+		t.Errorf(`HTTPError should carry status and request, %+v given`, httpErr)
+	}
+	if httpErr.Problem == nil || httpErr.Problem.Detail != "balance is 30" || httpErr.Problem.Extensions["balance"] != float64(30) {
+		t.Errorf(`Problem details should be decoded, %+v given`, httpErr.Problem)
+	}
+
+	req, _ = Get(ts.URL + "/api")
+	_, err = req.SetErrorResult(&apiError{}).Do()
+	if !errors.As(err, &httpErr) {
+		t.Fatalf(`Error should be *HTTPError, %v given`, err)
+	}
+	if result, ok := httpErr.Result.(*apiError); !ok || result.Code != "not_found" {
+		t.Errorf(`Error result should be decoded, %+v given`, httpErr.Result)
+	}
+
+	req, _ = Get(ts.URL + "/api")
+	if _, err := req.Do(); err != nil {
+		t.Errorf(`Without policy Do should not fail, %v given`, err)
+	}
+
+	req, _ = client.Get(ts.URL + "/ok")
+	if _, err := req.Do(); err != nil {
+		t.Errorf(`2xx should not fail, %v given`, err)
+	}
+}
+
+func Test_HTTPErrorGeneric(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"code":"boom","message":"internal"}`))
+	}))
+	defer ts.Close()
+
+	_, _, err := GetAs[testStruct](NewClient().SetErrorResult(apiError{}), ts.URL)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf(`Error should be *HTTPError, %v given`, err)
+	}
+	if result, ok := httpErr.Result.(*apiError); !ok || result.Code != "boom" {
+		t.Errorf(`Error result should be decoded, %+v given`, httpErr.Result)
+	}
+}
+
+func Test_HTTPErrorWithoutRequest(t *testing.T) {
+	e := &HTTPError{StatusCode: http.StatusBadGateway, Body: []byte("upstream down")}
+	if e.Error() != "502 Bad Gateway: upstream down" {
+		t.Errorf(`Error should be "%s", "%s" given`, "502 Bad Gateway: upstream down", e.Error())
+	}
+	if (&HTTPError{}).Error() != "0" {
+		t.Errorf(`Error of a zero HTTPError should be "%s", "%s" given`, "0", (&HTTPError{}).Error())
+	}
+	var v apiError
+	if err := e.Decode(&v); err == nil {
+		t.Errorf(`Decode without response should be an error`)
+	}
+}
+
+func Test_NewRequestMethod(t *testing.T) {
+	if _, err := NewRequest("FETCH", "http://example.com"); err != NoMatchHttpMethod {
+		t.Errorf(`Error should be %v, %v given`, NoMatchHttpMethod, err)
+	}
+}
@@ -0,0 +1,739 @@
+package okhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPathSegment selects the children of the nodes matched so far, or of all their descendants when recursive
+type jsonPathSegment struct {
+	recursive bool
+	selectors []jsonPathSelector
+}
+
+type jsonPathSelectorKind int
+
+const (
+	selectName jsonPathSelectorKind = iota
+	selectWildcard
+	selectIndex
+	selectSlice
+	selectFilter
+)
+
+type jsonPathSelector struct {
+	kind   jsonPathSelectorKind
+	name   string
+	index  int
+	slice  [3]*int
+	filter jsonFilter
+}
+
+// JSONPath is a compiled JSONPath like $.items[?(@.price < 10)].name,
+// a path without $ is a dotted path like items.0.name
+type JSONPath struct {
+	path     string
+	segments []jsonPathSegment
+}
+
+// CompileJSONPath compiles a JSONPath or a dotted path
+func CompileJSONPath(path string) (*JSONPath, error) {
+	s := strings.TrimSpace(path)
+	switch {
+	case strings.HasPrefix(s, "$"):
+		s = s[1:]
+	case s == "":
+	case s[0] != '.' && s[0] != '[':
+		s = "." + s
+	}
+	p := &jsonPathParser{s: s}
+	segments, err := p.segments()
+	if err != nil {
+		return nil, fmt.Errorf("jsonpath %q: %w", path, err)
+	}
+	return &JSONPath{path: path, segments: segments}, nil
+}
+
+// String returns the source of the path
+func (p *JSONPath) String() string {
+	return p.path
+}
+
+// Singular reports whether the path selects at most one node, it has no wildcard, slice, union, filter or recursion
+func (p *JSONPath) Singular() bool {
+	for _, seg := range p.segments {
+		if seg.recursive || len(seg.selectors) != 1 {
+			return false
+		}
+		if k := seg.selectors[0].kind; k != selectName && k != selectIndex {
+			return false
+		}
+	}
+	return true
+}
+
+// Find returns the nodes of a document decoded by encoding/json which are matched by the path
+func (p *JSONPath) Find(doc interface{}) []interface{} {
+	return evalJSONPath(p.segments, doc, doc)
+}
+
+func evalJSONPath(segments []jsonPathSegment, node, root interface{}) []interface{} {
+	nodes := []interface{}{node}
+	for _, seg := range segments {
+		var next []interface{}
+		for _, n := range nodes {
+			targets := []interface{}{n}
+			if seg.recursive {
+				targets = descendants(n, targets)
+			}
+			for _, t := range targets {
+				for _, sel := range seg.selectors {
+					next = sel.apply(t, root, next)
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// descendants appends every node below n in document order
+func descendants(n interface{}, out []interface{}) []interface{} {
+	for _, c := range children(n) {
+		out = append(out, c)
+		out = descendants(c, out)
+	}
+	return out
+}
+
+// children returns the elements of an array or the values of an object sorted by key
+func children(n interface{}) []interface{} {
+	switch v := n.(type) {
+	case []interface{}:
+		return v
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make([]interface{}, len(keys))
+		for i, k := range keys {
+			out[i] = v[k]
+		}
+		return out
+	}
+	return nil
+}
+
+func (sel jsonPathSelector) apply(n, root interface{}, out []interface{}) []interface{} {
+	switch sel.kind {
+	case selectName:
+		switch v := n.(type) {
+		case map[string]interface{}:
+			if c, ok := v[sel.name]; ok {
+				out = append(out, c)
+			}
+		case []interface{}:
+			// dotted paths index arrays like items.0
+			if i, err := strconv.Atoi(sel.name); err == nil && i >= 0 && i < len(v) {
+				out = append(out, v[i])
+			}
+		}
+	case selectWildcard:
+		out = append(out, children(n)...)
+	case selectIndex:
+		if v, ok := n.([]interface{}); ok {
+			i := sel.index
+			if i < 0 {
+				i += len(v)
+			}
+			if i >= 0 && i < len(v) {
+				out = append(out, v[i])
+			}
+		}
+	case selectSlice:
+		if v, ok := n.([]interface{}); ok {
+			out = append(out, sliceJSONArray(v, sel.slice)...)
+		}
+	case selectFilter:
+		for _, c := range children(n) {
+			if sel.filter.match(c, root) {
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+// sliceJSONArray applies a [start:end:step] slice with the semantics of RFC 9535
+func sliceJSONArray(v []interface{}, s [3]*int) []interface{} {
+	n := len(v)
+	step := 1
+	if s[2] != nil {
+		step = *s[2]
+	}
+	if step == 0 {
+		return nil
+	}
+	normalize := func(i int) int {
+		if i < 0 {
+			return i + n
+		}
+		return i
+	}
+	var out []interface{}
+	if step > 0 {
+		start, end := 0, n
+		if s[0] != nil {
+			start = normalize(*s[0])
+		}
+		if s[1] != nil {
+			end = normalize(*s[1])
+		}
+		start, end = clampInt(start, 0, n), clampInt(end, 0, n)
+		for i := start; i < end; i += step {
+			out = append(out, v[i])
+			// i+step may overflow with a large step
+			if end-i <= step {
+				break
+			}
+		}
+		return out
+	}
+	start, end := n-1, -n-1
+	if s[0] != nil {
+		start = normalize(*s[0])
+	}
+	if s[1] != nil {
+		end = normalize(*s[1])
+	}
+	start, end = clampInt(start, -1, n-1), clampInt(end, -1, n-1)
+	for i := start; i > end; i += step {
+		out = append(out, v[i])
+		if end-i >= step {
+			break
+		}
+	}
+	return out
+}
+
+func clampInt(i, min, max int) int {
+	if i < min {
+		return min
+	}
+	if i > max {
+		return max
+	}
+	return i
+}
+
+type jsonPathParser struct {
+	s string
+	i int
+}
+
+func (p *jsonPathParser) segments() ([]jsonPathSegment, error) {
+	var segments []jsonPathSegment
+	for p.i < len(p.s) {
+		seg := jsonPathSegment{}
+		switch p.s[p.i] {
+		case '.':
+			p.i++
+			if p.peek() == '.' {
+				p.i++
+				seg.recursive = true
+			}
+			switch p.peek() {
+			case '[':
+				if !seg.recursive {
+					return nil, fmt.Errorf("unexpected [ at %d", p.i)
+				}
+				sels, err := p.bracket()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = sels
+			case '*':
+				p.i++
+				seg.selectors = []jsonPathSelector{{kind: selectWildcard}}
+			default:
+				start := p.i
+				for p.i < len(p.s) && !strings.ContainsRune(".[ ", rune(p.s[p.i])) {
+					p.i++
+				}
+				if p.i == start {
+					return nil, fmt.Errorf("empty name at %d", start)
+				}
+				seg.selectors = []jsonPathSelector{{kind: selectName, name: p.s[start:p.i]}}
+			}
+		case '[':
+			sels, err := p.bracket()
+			if err != nil {
+				return nil, err
+			}
+			seg.selectors = sels
+		case ' ':
+			p.i++
+			continue
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", p.s[p.i], p.i)
+		}
+		segments = append(segments, seg)
+	}
+	return segments, nil
+}
+
+func (p *jsonPathParser) peek() byte {
+	if p.i < len(p.s) {
+		return p.s[p.i]
+	}
+	return 0
+}
+
+func (p *jsonPathParser) skipSpaces() {
+	for p.i < len(p.s) && p.s[p.i] == ' ' {
+		p.i++
+	}
+}
+
+// bracket parses [...] with a filter or a union of names, indexes, slices and wildcards
+func (p *jsonPathParser) bracket() ([]jsonPathSelector, error) {
+	p.i++
+	p.skipSpaces()
+	if p.peek() == '?' {
+		p.i++
+		p.skipSpaces()
+		fp := &jsonFilterParser{s: p.s, i: p.i}
+		f, err := fp.parse()
+		if err != nil {
+			return nil, err
+		}
+		p.i = fp.i
+		p.skipSpaces()
+		if p.peek() != ']' {
+			return nil, fmt.Errorf("unclosed filter at %d", p.i)
+		}
+		p.i++
+		return []jsonPathSelector{{kind: selectFilter, filter: f}}, nil
+	}
+
+	var sels []jsonPathSelector
+	for {
+		p.skipSpaces()
+		switch c := p.peek(); {
+		case c == '*':
+			p.i++
+			sels = append(sels, jsonPathSelector{kind: selectWildcard})
+		case c == '\'' || c == '"':
+			s, err := readQuoted(p.s, &p.i)
+			if err != nil {
+				return nil, err
+			}
+			sels = append(sels, jsonPathSelector{kind: selectName, name: s})
+		default:
+			start := p.i
+			for p.i < len(p.s) && strings.IndexByte("-0123456789: ", p.s[p.i]) >= 0 {
+				p.i++
+			}
+			sel, err := parseIndexOrSlice(strings.TrimSpace(p.s[start:p.i]))
+			if err != nil {
+				return nil, err
+			}
+			sels = append(sels, sel)
+		}
+		p.skipSpaces()
+		switch p.peek() {
+		case ',':
+			p.i++
+		case ']':
+			p.i++
+			return sels, nil
+		default:
+			return nil, fmt.Errorf("unclosed [ at %d", p.i)
+		}
+	}
+}
+
+func parseIndexOrSlice(s string) (jsonPathSelector, error) {
+	if !strings.Contains(s, ":") {
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return jsonPathSelector{}, fmt.Errorf("bad index %q", s)
+		}
+		return jsonPathSelector{kind: selectIndex, index: i}, nil
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return jsonPathSelector{}, fmt.Errorf("bad slice %q", s)
+	}
+	sel := jsonPathSelector{kind: selectSlice}
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return jsonPathSelector{}, fmt.Errorf("bad slice %q", s)
+		}
+		sel.slice[i] = &n
+	}
+	return sel, nil
+}
+
+// readQuoted reads a single or double quoted string at *i
+func readQuoted(s string, i *int) (string, error) {
+	quote := s[*i]
+	b := strings.Builder{}
+	for j := *i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			if j+1 < len(s) {
+				j++
+				b.WriteByte(s[j])
+			}
+		case quote:
+			*i = j + 1
+			return b.String(), nil
+		default:
+			b.WriteByte(s[j])
+		}
+	}
+	return "", fmt.Errorf("unclosed string at %d", *i)
+}
+
+// jsonFilter is the expression of a [?(...)] selector
+type jsonFilter interface {
+	match(node, root interface{}) bool
+}
+
+type filterOr struct{ left, right jsonFilter }
+
+func (f filterOr) match(n, root interface{}) bool {
+	return f.left.match(n, root) || f.right.match(n, root)
+}
+
+type filterAnd struct{ left, right jsonFilter }
+
+func (f filterAnd) match(n, root interface{}) bool {
+	return f.left.match(n, root) && f.right.match(n, root)
+}
+
+type filterNot struct{ f jsonFilter }
+
+func (f filterNot) match(n, root interface{}) bool { return !f.f.match(n, root) }
+
+// filterExists is a path without comparison, true when it matches a node
+type filterExists struct{ operand filterOperand }
+
+func (f filterExists) match(n, root interface{}) bool {
+	_, ok := f.operand.value(n, root)
+	return ok
+}
+
+type filterCompare struct {
+	op          string
+	left, right filterOperand
+	re          *regexp.Regexp
+}
+
+func (f filterCompare) match(n, root interface{}) bool {
+	l, lok := f.left.value(n, root)
+	if f.op == "=~" {
+		s, ok := l.(string)
+		return lok && ok && f.re.MatchString(s)
+	}
+	r, rok := f.right.value(n, root)
+	if !lok || !rok {
+		return f.op == "!=" && lok != rok
+	}
+	switch f.op {
+	case "==":
+		return jsonEqual(l, r)
+	case "!=":
+		return !jsonEqual(l, r)
+	}
+	c, ok := jsonCompare(l, r)
+	if !ok {
+		return false
+	}
+	switch f.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// filterOperand is a literal or a singular path relative to @ or $
+type filterOperand struct {
+	literal  interface{}
+	path     []jsonPathSegment
+	relative bool
+	isPath   bool
+}
+
+func (o filterOperand) value(n, root interface{}) (interface{}, bool) {
+	if !o.isPath {
+		return o.literal, true
+	}
+	start := root
+	if o.relative {
+		start = n
+	}
+	nodes := evalJSONPath(o.path, start, root)
+	if len(nodes) == 0 {
+		return nil, false
+	}
+	return nodes[0], true
+}
+
+func jsonNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func jsonEqual(l, r interface{}) bool {
+	if a, ok := jsonNumber(l); ok {
+		b, ok := jsonNumber(r)
+		return ok && a == b
+	}
+	switch a := l.(type) {
+	case string, bool, nil:
+		return l == r
+	case []interface{}, map[string]interface{}:
+		x, _ := json.Marshal(a)
+		y, _ := json.Marshal(r)
+		return string(x) == string(y)
+	}
+	return false
+}
+
+func jsonCompare(l, r interface{}) (int, bool) {
+	if a, ok := jsonNumber(l); ok {
+		b, ok := jsonNumber(r)
+		switch {
+		case !ok:
+			return 0, false
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		}
+		return 0, true
+	}
+	if a, ok := l.(string); ok {
+		b, ok := r.(string)
+		return strings.Compare(a, b), ok
+	}
+	return 0, false
+}
+
+type jsonFilterParser struct {
+	s string
+	i int
+}
+
+func (p *jsonFilterParser) skipSpaces() {
+	for p.i < len(p.s) && p.s[p.i] == ' ' {
+		p.i++
+	}
+}
+
+func (p *jsonFilterParser) consume(tok string) bool {
+	p.skipSpaces()
+	if strings.HasPrefix(p.s[p.i:], tok) {
+		p.i += len(tok)
+		return true
+	}
+	return false
+}
+
+func (p *jsonFilterParser) parse() (jsonFilter, error) {
+	return p.or()
+}
+
+func (p *jsonFilterParser) or() (jsonFilter, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("||") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = filterOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *jsonFilterParser) and() (jsonFilter, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("&&") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = filterAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *jsonFilterParser) unary() (jsonFilter, error) {
+	if p.consume("!") {
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{f}, nil
+	}
+	if p.consume("(") {
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, fmt.Errorf("unclosed ( at %d", p.i)
+		}
+		return f, nil
+	}
+	return p.comparison()
+}
+
+func (p *jsonFilterParser) comparison() (jsonFilter, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	if p.consume("=~") {
+		p.skipSpaces()
+		re, err := p.regexp()
+		if err != nil {
+			return nil, err
+		}
+		return filterCompare{op: "=~", left: left, re: re}, nil
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			right, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			return filterCompare{op: op, left: left, right: right}, nil
+		}
+	}
+	if !left.isPath {
+		return nil, fmt.Errorf("literal without comparison at %d", p.i)
+	}
+	return filterExists{left}, nil
+}
+
+// regexp parses /pattern/ with an optional i flag
+func (p *jsonFilterParser) regexp() (*regexp.Regexp, error) {
+	if p.i >= len(p.s) || p.s[p.i] != '/' {
+		return nil, fmt.Errorf("expected /pattern/ at %d", p.i)
+	}
+	b := strings.Builder{}
+	for j := p.i + 1; j < len(p.s); j++ {
+		switch p.s[j] {
+		case '\\':
+			if j+1 < len(p.s) && p.s[j+1] == '/' {
+				j++
+			}
+			b.WriteByte(p.s[j])
+		case '/':
+			p.i = j + 1
+			pattern := b.String()
+			if p.i < len(p.s) && p.s[p.i] == 'i' {
+				p.i++
+				pattern = "(?i)" + pattern
+			}
+			return regexp.Compile(pattern)
+		default:
+			b.WriteByte(p.s[j])
+		}
+	}
+	return nil, fmt.Errorf("unclosed regexp at %d", p.i)
+}
+
+func (p *jsonFilterParser) operand() (filterOperand, error) {
+	p.skipSpaces()
+	if p.i >= len(p.s) {
+		return filterOperand{}, fmt.Errorf("unexpected end of filter")
+	}
+	switch c := p.s[p.i]; {
+	case c == '@' || c == '$':
+		p.i++
+		start := p.i
+		depth := 0
+		for p.i < len(p.s) {
+			ch := p.s[p.i]
+			if ch == '[' {
+				depth++
+			} else if ch == ']' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			} else if depth == 0 && !isPathChar(ch) {
+				break
+			} else if ch == '\'' || ch == '"' {
+				if _, err := readQuoted(p.s, &p.i); err != nil {
+					return filterOperand{}, err
+				}
+				continue
+			}
+			p.i++
+		}
+		pp := &jsonPathParser{s: p.s[start:p.i]}
+		segments, err := pp.segments()
+		if err != nil {
+			return filterOperand{}, err
+		}
+		return filterOperand{path: segments, relative: c == '@', isPath: true}, nil
+	case c == '\'' || c == '"':
+		s, err := readQuoted(p.s, &p.i)
+		return filterOperand{literal: s}, err
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.i
+		for p.i < len(p.s) && strings.IndexByte("+-.eE0123456789", p.s[p.i]) >= 0 {
+			p.i++
+		}
+		if _, err := strconv.ParseFloat(p.s[start:p.i], 64); err != nil {
+			return filterOperand{}, fmt.Errorf("bad number %q", p.s[start:p.i])
+		}
+		return filterOperand{literal: json.Number(p.s[start:p.i])}, nil
+	}
+	for word, v := range map[string]interface{}{"true": true, "false": false, "null": nil} {
+		if strings.HasPrefix(p.s[p.i:], word) {
+			p.i += len(word)
+			return filterOperand{literal: v}, nil
+		}
+	}
+	return filterOperand{}, fmt.Errorf("unexpected %q in filter at %d", p.s[p.i], p.i)
+}
+
+func isPathChar(c byte) bool {
+	return c == '.' || c == '_' || c == '*' || c == '-' || c == '[' ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c >= 0x80
+}
@@ -0,0 +1,95 @@
+package okhttp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const jsonPathStore = `{
+	"store": {
+		"book": [
+			{"category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95},
+			{"category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99},
+			{"category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99},
+			{"category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99}
+		],
+		"bicycle": {"color": "red", "price": 19.95, "sold": false}
+	},
+	"total": "4",
+	"id": 9007199254740993
+}`
+
+func Test_JSONPath(t *testing.T) {
+	resp := &Response{body: []byte(jsonPathStore)}
+	cases := map[string]string{
+		"$.store.book[*].author":                     "Nigel Rees,Evelyn Waugh,Herman Melville,J. R. R. Tolkien",
+		"$..author":                                  "Nigel Rees,Evelyn Waugh,Herman Melville,J. R. R. Tolkien",
+		"$.store.book[2].title":                      "Moby Dick",
+		"$.store.book[-1].title":                     "The Lord of the Rings",
+		"$.store.book[0,1].price":                    "8.95,12.99",
+		"$.store.book[:2].price":                     "8.95,12.99",
+		"$.store.book[::-2].price":                   "22.99,12.99",
+		"$.store.book[1::9223372036854775807].price": "12.99",
+		"$.store.book[::-9223372036854775808].price": "22.99",
+		"$.store.book[2:-9223372036854775808:-9223372036854775807].price": "8.99",
+		"$.store.book[?(@.isbn)].title":                                   "Moby Dick,The Lord of the Rings",
+		"$.store.book[?(@.price < 10)].title":                             "Sayings of the Century,Moby Dick",
+		"$..book[?(@.category == 'fiction' && @.price > 20)].title":       "The Lord of the Rings",
+		"$..book[?(@.author =~ /tolkien/i)].price":                        "22.99",
+		"$..book[?(!@.isbn || @.price >= $.store.bicycle.price)].price":   "8.95,12.99,22.99",
+		"$.store['bicycle'].color":                                        "red",
+		"store.book.1.author":                                             "Evelyn Waugh",
+		"$.missing":                                                       "",
+	}
+	for path, want := range cases {
+		values, err := resp.JSONPath(path)
+		if err != nil {
+			t.Errorf(`%s should have no error, %v given`, path, err)
+			continue
+		}
+		got := make([]string, len(values))
+		for i, v := range values {
+			got[i] = fmt.Sprint(v)
+		}
+		if strings.Join(got, ",") != want {
+			t.Errorf(`%s should be "%s", "%s" given`, path, want, strings.Join(got, ","))
+		}
+	}
+
+	for _, path := range []string{"$.store.book[", "$.store.book[?(@.price <)]", "$..book[a]"} {
+		if _, err := resp.JSONPath(path); err == nil {
+			t.Errorf(`%s should be an error`, path)
+		}
+	}
+}
+
+func Test_JSONPathGetters(t *testing.T) {
+	resp := &Response{body: []byte(jsonPathStore)}
+	if resp.GetString("store.bicycle.color") != "red" {
+		t.Errorf(`GetString should be "%s", "%s" given`, "red", resp.GetString("store.bicycle.color"))
+	}
+	if resp.GetInt("id") != 9007199254740993 {
+		t.Errorf(`GetInt should be %d, %d given`, int64(9007199254740993), resp.GetInt("id"))
+	}
+	if resp.GetInt("total") != 4 {
+		t.Errorf(`GetInt of a string should be %d, %d given`, 4, resp.GetInt("total"))
+	}
+	if resp.GetFloat("$.store.bicycle.price") != 19.95 {
+		t.Errorf(`GetFloat should be %v, %v given`, 19.95, resp.GetFloat("$.store.bicycle.price"))
+	}
+	if resp.GetBool("store.bicycle.sold") || !resp.Exists("store.bicycle.sold") || resp.Exists("store.car") {
+		t.Errorf(`GetBool and Exists should see a false value`)
+	}
+	if len(resp.GetArray("store.book")) != 4 || len(resp.GetArray("$..book[?(@.isbn)]")) != 2 {
+		t.Errorf(`GetArray should be %d, %d given`, 4, len(resp.GetArray("store.book")))
+	}
+	if resp.GetString("store.book.0.price") != "8.95" {
+		t.Errorf(`GetString of a number should be "%s", "%s" given`, "8.95", resp.GetString("store.book.0.price"))
+	}
+
+	resp.body = []byte(`{}`)
+	if resp.GetString("store.bicycle.color") != "red" {
+		t.Errorf(`the document should be parsed once`)
+	}
+}
@@ -78,6 +78,14 @@ func (t *traceError) Error() string {
 	return fmt.Sprintf(t.error.Error(), t.format...)
 }
 
+// Unwrap returns the traced error, errors.Is and errors.As see through the trace
+func (t *traceError) Unwrap() error {
+	if t == nil {
+		return nil
+	}
+	return t.error
+}
+
 func (t *traceError) Trace(info ...interface{}) *traceError {
 	return TraceEx(1, t, info...)
 }
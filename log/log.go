@@ -6,26 +6,30 @@ import (
 	"io"
 	"log"
 	"os"
-	"path"
 	"runtime"
-	"strconv"
 	"strings"
 )
 
+// DebugLevel is the level of the loggers without their own level set by SetLevel,
+// 0 prints the debug logs and 2 hides the info logs
 var DebugLevel = 1
 
 type Logger struct {
-	depth  int
-	reqid  string
-	Logger *log.Logger
+	depth    int
+	reqid    string
+	Logger   *log.Logger
+	level    Level
+	hasLevel bool
+	format   Format
+	fields   []interface{}
 }
 
 func NewLogger(l int) *Logger {
-	return &Logger{l, "", goLogStd}
+	return &Logger{depth: l, Logger: goLogStd}
 }
 
 func NewLoggerEx(w io.Writer) *Logger {
-	return &Logger{0, "", NewGLog(w)}
+	return &Logger{Logger: NewGLog(w)}
 }
 
 func NewGLog(w io.Writer) *log.Logger {
@@ -60,7 +64,9 @@ func SetStd(l *Logger) {
 	Infof = std.Infof
 	Info = std.Info
 	Debug = std.Debug
+	Debugf = std.Debugf
 	Error = std.Error
+	Errorf = std.Errorf
 	Warn = std.Warn
 	PrintStack = std.PrintStack
 	Stack = std.Stack
@@ -101,9 +107,21 @@ func DownLevel(i int) Logger {
 	return std.DownLevel(i - 1)
 }
 
+// WithReqID returns a copy of the logger printing a request id like a trace id in its prefix
+func (l Logger) WithReqID(id string) Logger {
+	l.reqid = id
+	return l
+}
+
+// ReqID returns the request id printed in the prefix
+func (l Logger) ReqID() string {
+	return l.reqid
+}
+
 // DownLevel decide to show which level's stack
 func (l Logger) DownLevel(i int) Logger {
-	return Logger{l.depth + i, l.reqid, l.Logger}
+	l.depth += i
+	return l
 }
 
 //Pretty  output objects to json format
@@ -114,104 +132,115 @@ func (l Logger) PrettyJSON(os ...interface{}) {
 			content += string(ret) + "\n"
 		}
 	}
-	l.Output(2, PRETTY+content)
+	l.emit(2, LevelInfo, PRETTY, content, nil)
 }
 
 // Print just print
 func (l Logger) Print(o ...interface{}) {
-	l.Output(2, sprint(o))
+	l.emit(2, LevelInfo, "", sprint(o), nil)
 }
 
 //Printf  just print by format
 func (l Logger) Printf(layout string, o ...interface{}) {
-	l.Output(2, sprintf(layout, o))
+	l.emit(2, LevelInfo, "", sprintf(layout, o), nil)
 }
 
 //Println  just println
 func (l Logger) Println(o ...interface{}) {
-	l.Output(2, " "+sprint(o))
+	l.emit(2, LevelInfo, " ", sprint(o), nil)
 }
 
 //Info  just println
 func (l Logger) Info(o ...interface{}) {
-	if DebugLevel > 1 {
+	if !l.Enabled(LevelInfo) {
 		return
 	}
-
-	l.Output(2, INFO+sprint(o))
+	l.emit(2, LevelInfo, INFO, sprint(o), nil)
 }
 
 //Infof  just println
 func (l Logger) Infof(format string, o ...interface{}) {
-	if DebugLevel > 1 {
+	if !l.Enabled(LevelInfo) {
 		return
 	}
-	l.Output(2, INFO+sprintf(format, o))
+	l.emit(2, LevelInfo, INFO, sprintf(format, o), nil)
 }
 
 //Debug  just println
 func (l Logger) Debug(o ...interface{}) {
-	if DebugLevel > 0 {
+	if !l.Enabled(LevelDebug) {
 		return
 	}
-	l.Output(2, DEBUG+sprint(o))
+	l.emit(2, LevelDebug, DEBUG, sprint(o), nil)
 }
 
 //Debugf  just println
 func (l Logger) Debugf(f string, o ...interface{}) {
-	if DebugLevel > 0 {
+	if !l.Enabled(LevelDebug) {
 		return
 	}
-	l.Output(2, DEBUG+sprintf(f, o))
+	l.emit(2, LevelDebug, DEBUG, sprintf(f, o), nil)
 }
 
 //Todo  just println
 func (l Logger) Todo(o ...interface{}) {
-	l.Output(2, TODO+sprint(o))
+	l.emit(2, LevelInfo, TODO, sprint(o), nil)
 }
 
 //Error  just println
 func (l Logger) Error(o ...interface{}) {
-	l.Output(2, ERROR+sprint(o))
+	if !l.Enabled(LevelError) {
+		return
+	}
+	l.emit(2, LevelError, ERROR, sprint(o), nil)
 }
 
 //Errorf  just println
 func (l Logger) Errorf(f string, o ...interface{}) {
-	l.Output(2, ERROR+sprintf(f, o))
+	if !l.Enabled(LevelError) {
+		return
+	}
+	l.emit(2, LevelError, ERROR, sprintf(f, o), nil)
 }
 
 //Warn  just println
 func (l Logger) Warn(o ...interface{}) {
-	l.Output(2, WARN+sprint(o))
+	if !l.Enabled(LevelWarn) {
+		return
+	}
+	l.emit(2, LevelWarn, WARN, sprint(o), nil)
 }
 
 //Warnf  just println
 func (l Logger) Warnf(f string, o ...interface{}) {
-	l.Output(2, WARN+sprintf(f, o))
+	if !l.Enabled(LevelWarn) {
+		return
+	}
+	l.emit(2, LevelWarn, WARN, sprintf(f, o), nil)
 }
 
 //Panic  just println
 func (l Logger) Panic(o ...interface{}) {
-	l.Output(2, PANIC+sprint(o))
+	l.emit(2, LevelPanic, PANIC, sprint(o), nil)
 	panic(o)
 }
 
 //Panicf  just println
 func (l Logger) Panicf(f string, o ...interface{}) {
 	info := sprintf(f, o)
-	l.Output(2, PANIC+info)
+	l.emit(2, LevelPanic, PANIC, info, nil)
 	panic(info)
 }
 
 //Fatal  just println
 func (l Logger) Fatal(o ...interface{}) {
-	l.Output(2, FATAL+sprint(o))
+	l.emit(2, LevelFatal, FATAL, sprint(o), nil)
 	os.Exit(1)
 }
 
 //Fatalf  just println
 func (l Logger) Fatalf(f string, o ...interface{}) {
-	l.Output(2, FATAL+sprintf(f, o))
+	l.emit(2, LevelFatal, FATAL, sprintf(f, o), nil)
 	os.Exit(1)
 }
 
@@ -225,7 +254,7 @@ func (l Logger) Struct(o ...interface{}) {
 	if len(layout) > 0 {
 		layout = layout[2:]
 	}
-	l.Output(2, STRUCT+sprintf(layout, items))
+	l.emit(2, LevelInfo, STRUCT, sprintf(layout, items), nil)
 }
 
 //PrintStack  just println
@@ -251,14 +280,9 @@ func (l Logger) Output(callDepth int, s string) error {
 
 func (l Logger) makePrefix(callDepth int) string {
 
-	pc, f, line, _ := runtime.Caller(callDepth)
-	name := runtime.FuncForPC(pc).Name()
-	name = path.Base(name)
-	f = path.Base(f)
-
 	tags := make([]string, 0, 3)
 
-	pos := name + ":" + f + ":" + strconv.Itoa(line)
+	pos := caller(callDepth)
 	tags = append(tags, pos)
 	if l.reqid != "" {
 		tags = append(tags, l.reqid)
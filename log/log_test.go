@@ -2,24 +2,28 @@ package log
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"io"
+	"log/slog"
 	"strings"
 	"testing"
+	"time"
 )
 
 func (s *S) hello() {
 	s.Warn("warn in hello")
 }
 
-func log(buf io.Writer, s string) {
-	Logger{Logger: NewGoLog(buf)}.Output(2, s)
+func output(buf io.Writer, s string) {
+	Logger{Logger: NewGLog(buf)}.Output(2, s)
 }
 
 func test(buf io.Writer) {
-	log(buf, "aa")
-	Info("b")
-	NewLoggerEx(buf).Info("c")
-	Error("ec")
+	output(buf, "hello world")
+	Info("welcome to china")
+	NewLoggerEx(buf).Info("xixi")
+	Error("this is nb")
 	s.hello()
 	Struct(&s, 1, "", false)
 }
@@ -35,28 +39,122 @@ var s = S{}
 func TestLog(t *testing.T) {
 	buf := bytes.NewBuffer(nil)
 	logger := NewLoggerEx(buf)
-	logger.depth = 1
+	old, oldStd := goLogStd, std
+	defer func() {
+		goLogStd = old
+		SetStd(oldStd)
+	}()
 	goLogStd = logger.Logger
 	SetStd(logger)
 
 	test(buf)
 	ret := buf.String()
 
-	println("====================\n", ret)
-
 	except := []string{
-		".test:log_test.go:19]hello world",
-		".test:log_test.go:20][INFO] welcome to china",
-		".test:log_test.go:21][INFO] xixi",
-		".test:log_test.go:22][ERROR] this is nb",
-		".(*S).hello:log_test.go:11][WARN] warn in hello",
+		".test:log_test.go:23]hello world",
+		".test:log_test.go:24][INFO] welcome to china",
+		".test:log_test.go:25][INFO] xixi",
+		".test:log_test.go:26][ERROR] this is nb",
+		".(*S).hello:log_test.go:15][WARN] warn in hello",
 	}
 
 	for _, e := range except {
 		idx := strings.Index(ret, e)
 		if idx < 0 {
-			t.Fatal("except", e, "not found")
+			t.Fatal("except", e, "not found in", ret)
 		}
 		ret = ret[idx+len(e):]
 	}
 }
+
+func TestSetStd(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	oldStd, oldLevel := std, DebugLevel
+	defer func() {
+		SetStd(oldStd)
+		DebugLevel = oldLevel
+	}()
+	DebugLevel = 0
+	SetStd(NewLoggerEx(buf))
+
+	Debugf("debug %d", 1)
+	Errorf("error %d", 2)
+	if !strings.Contains(buf.String(), "[DEBUG] debug 1") || !strings.Contains(buf.String(), "[ERROR] error 2") {
+		t.Errorf(`Debugf and Errorf should use the std logger, "%s" given`, buf.String())
+	}
+}
+
+func TestLevel(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	l := NewLoggerEx(buf).SetLevel(LevelWarn)
+	l.Debug("debug")
+	l.Info("info")
+	l.Warn("warn")
+	l.Errorw("error")
+	if got := buf.String(); strings.Contains(got, "debug") || strings.Contains(got, "info") || !strings.Contains(got, "[WARN] warn") || !strings.Contains(got, "[ERROR] error") {
+		t.Errorf(`a warn logger should print warn and error, "%s" given`, got)
+	}
+
+	// the level of a logger does not change the other loggers
+	other := NewLoggerEx(buf).SetLevel(LevelDebug)
+	other.Debug("other debug")
+	if !strings.Contains(buf.String(), "[DEBUG] other debug") {
+		t.Errorf(`a debug logger should print debug, "%s" given`, buf.String())
+	}
+}
+
+func TestFormat(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	l := NewLoggerEx(buf).SetFormat(FormatJSON).WithReqID("4bf92f35").With("service", "api")
+	l.Infow("request done", "status", 200, "took", 1500*time.Millisecond, "err", errors.New("boom"))
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf(`JSON format should print a JSON object, "%s" given`, buf.String())
+	}
+	want := map[string]interface{}{"level": "INFO", "reqid": "4bf92f35", "msg": "request done", "service": "api", "status": float64(200), "took": "1.5s", "err": "boom"}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf(`%s should be "%v", "%v" given`, k, v, line[k])
+		}
+	}
+	if !strings.HasPrefix(line["caller"].(string), "log.TestFormat:log_test.go:") {
+		t.Errorf(`caller should be the test, "%v" given`, line["caller"])
+	}
+
+	buf.Reset()
+	l = NewLoggerEx(buf).SetFormat(FormatLogfmt).With("service", "api")
+	l.Warn("slow", "call")
+	got := buf.String()
+	if !strings.HasPrefix(got, "time=") || !strings.Contains(got, ` level=WARN `) || !strings.HasSuffix(got, ` msg="slow call" service=api`+"\n") {
+		t.Errorf(`logfmt format should print key=value pairs, "%s" given`, got)
+	}
+
+	buf.Reset()
+	NewLoggerEx(buf).Infow("text", "key", "a value")
+	if !strings.Contains(buf.String(), `[INFO] text key="a value"`) {
+		t.Errorf(`text format should append the fields, "%s" given`, buf.String())
+	}
+}
+
+func TestSlogHandler(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	l := NewLoggerEx(buf).SetFormat(FormatJSON).SetLevel(LevelInfo)
+	logger := NewSlog(*l).With("service", "api").WithGroup("http")
+	logger.Debug("hidden")
+	logger.Info("request", "status", 200, slog.Group("url", "host", "example.com"))
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf(`slog should print through the logger, "%s" given`, buf.String())
+	}
+	want := map[string]interface{}{"level": "INFO", "msg": "request", "service": "api", "http.status": float64(200), "http.url.host": "example.com"}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf(`%s should be "%v", "%v" given`, k, v, line[k])
+		}
+	}
+	if !strings.HasPrefix(line["caller"].(string), "log.TestSlogHandler:log_test.go:") {
+		t.Errorf(`caller should be the test, "%v" given`, line["caller"])
+	}
+}
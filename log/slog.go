@@ -0,0 +1,108 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+)
+
+// slogHandler is a slog.Handler printing through a Logger
+type slogHandler struct {
+	l      Logger
+	attrs  []interface{}
+	prefix string
+}
+
+// NewSlogHandler returns a slog.Handler printing the records with the level, the format and the fields of l
+func NewSlogHandler(l Logger) slog.Handler {
+	return &slogHandler{l: l}
+}
+
+// NewSlog returns a *slog.Logger printing through l
+func NewSlog(l Logger) *slog.Logger {
+	return slog.New(NewSlogHandler(l))
+}
+
+func (h *slogHandler) Enabled(_ context.Context, lv slog.Level) bool {
+	return h.l.Enabled(fromSlogLevel(lv))
+}
+
+func (h *slogHandler) Handle(_ context.Context, r slog.Record) error {
+	pos := ""
+	if r.PC != 0 {
+		f, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		pos = position(f.PC, f.File, f.Line)
+	}
+	fields := append(make([]interface{}, 0, len(h.attrs)+2*r.NumAttrs()), h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, h.prefix, a)
+		return true
+	})
+	lv := fromSlogLevel(r.Level)
+	return h.l.print(pos, lv, levelTag(lv), r.Message, fields)
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = append([]interface{}(nil), h.attrs...)
+	for _, a := range attrs {
+		h2.attrs = appendAttr(h2.attrs, h.prefix, a)
+	}
+	return &h2
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix += name + "."
+	return &h2
+}
+
+// appendAttr flattens an attribute, the keys of a group are prefixed by its name
+func appendAttr(fields []interface{}, prefix string, a slog.Attr) []interface{} {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			fields = appendAttr(fields, prefix, ga)
+		}
+		return fields
+	}
+	if a.Key == "" {
+		return fields
+	}
+	return append(fields, prefix+a.Key, v.Any())
+}
+
+func fromSlogLevel(lv slog.Level) Level {
+	switch {
+	case lv < slog.LevelInfo:
+		return LevelDebug
+	case lv < slog.LevelWarn:
+		return LevelInfo
+	case lv < slog.LevelError:
+		return LevelWarn
+	}
+	return LevelError
+}
+
+// levelTag returns the prefix of a level in the text format
+func levelTag(lv Level) string {
+	switch lv {
+	case LevelDebug:
+		return DEBUG
+	case LevelInfo:
+		return INFO
+	case LevelWarn:
+		return WARN
+	case LevelPanic:
+		return PANIC
+	case LevelFatal:
+		return FATAL
+	}
+	return ERROR
+}
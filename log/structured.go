@@ -0,0 +1,258 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Level is the severity of a log
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelPanic
+	LevelFatal
+)
+
+func (lv Level) String() string {
+	switch lv {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	case LevelPanic:
+		return "PANIC"
+	case LevelFatal:
+		return "FATAL"
+	}
+	return "LEVEL(" + strconv.Itoa(int(lv)) + ")"
+}
+
+// Format is the output of a logger
+type Format int
+
+const (
+	// FormatText prints the prefixes like [INFO] followed by the fields as key=value
+	FormatText Format = iota
+	// FormatJSON prints a JSON object by line
+	FormatJSON
+	// FormatLogfmt prints key=value pairs by line
+	FormatLogfmt
+)
+
+// SetLevel set the lowest level printed by the logger, the logger uses DebugLevel until it's set
+func (l *Logger) SetLevel(lv Level) *Logger {
+	l.level = lv
+	l.hasLevel = true
+	return l
+}
+
+// SetFormat set the output format of the logger
+func (l *Logger) SetFormat(f Format) *Logger {
+	l.format = f
+	return l
+}
+
+// With returns a copy of the logger adding key/value fields to every log
+func (l Logger) With(keysAndValues ...interface{}) Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keysAndValues))
+	l.fields = append(append(fields, l.fields...), keysAndValues...)
+	return l
+}
+
+// Enabled reports whether the logger prints a level
+func (l Logger) Enabled(lv Level) bool {
+	if l.hasLevel {
+		return lv >= l.level
+	}
+	switch lv {
+	case LevelDebug:
+		return DebugLevel <= 0
+	case LevelInfo:
+		return DebugLevel <= 1
+	}
+	return true
+}
+
+// Debugw print a debug message with key/value fields
+func (l Logger) Debugw(msg string, keysAndValues ...interface{}) {
+	if l.Enabled(LevelDebug) {
+		l.emit(2, LevelDebug, DEBUG, msg, keysAndValues)
+	}
+}
+
+// Infow print a message with key/value fields
+func (l Logger) Infow(msg string, keysAndValues ...interface{}) {
+	if l.Enabled(LevelInfo) {
+		l.emit(2, LevelInfo, INFO, msg, keysAndValues)
+	}
+}
+
+// Warnw print a warning with key/value fields
+func (l Logger) Warnw(msg string, keysAndValues ...interface{}) {
+	if l.Enabled(LevelWarn) {
+		l.emit(2, LevelWarn, WARN, msg, keysAndValues)
+	}
+}
+
+// Errorw print an error with key/value fields
+func (l Logger) Errorw(msg string, keysAndValues ...interface{}) {
+	if l.Enabled(LevelError) {
+		l.emit(2, LevelError, ERROR, msg, keysAndValues)
+	}
+}
+
+// emit prints a log of the caller at callDepth, tag is the prefix of the text format
+func (l Logger) emit(callDepth int, lv Level, tag, msg string, keysAndValues []interface{}) error {
+	return l.print(caller(callDepth+l.depth), lv, tag, msg, keysAndValues)
+}
+
+// structuredMu serializes the lines written around the *log.Logger
+var structuredMu sync.Mutex
+
+func (l Logger) print(pos string, lv Level, tag, msg string, keysAndValues []interface{}) error {
+	out := l.Logger
+	if out == nil {
+		out = goLogStd
+	}
+	fields := l.fields
+	if len(keysAndValues) > 0 {
+		fields = append(append(make([]interface{}, 0, len(fields)+len(keysAndValues)), fields...), keysAndValues...)
+	}
+
+	if l.format == FormatText {
+		tags := []string{pos}
+		if l.reqid != "" {
+			tags = append(tags, l.reqid)
+		}
+		buf := &bytes.Buffer{}
+		writeLogfmt(buf, fields)
+		return out.Output(0, "["+strings.Join(tags, "][")+"]"+tag+msg+buf.String())
+	}
+
+	head := []interface{}{"time", time.Now().Format(time.RFC3339Nano), "level", lv.String(), "caller", pos}
+	if l.reqid != "" {
+		head = append(head, "reqid", l.reqid)
+	}
+	head = append(head, "msg", msg)
+	fields = append(head, fields...)
+
+	buf := &bytes.Buffer{}
+	if l.format == FormatJSON {
+		writeJSON(buf, fields)
+	} else {
+		writeLogfmt(buf, fields)
+		buf.Next(1)
+	}
+	buf.WriteByte('\n')
+	structuredMu.Lock()
+	defer structuredMu.Unlock()
+	_, err := out.Writer().Write(buf.Bytes())
+	return err
+}
+
+// caller returns the position of a caller like pkg.Func:file.go:12
+func caller(skip int) string {
+	pc, f, line, _ := runtime.Caller(skip + 1)
+	return position(pc, f, line)
+}
+
+func position(pc uintptr, f string, line int) string {
+	name := ""
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		name = path.Base(fn.Name())
+	}
+	return name + ":" + path.Base(f) + ":" + strconv.Itoa(line)
+}
+
+// pairs calls f with the key/value pairs, a value without key gets the key !BADKEY
+func pairs(keysAndValues []interface{}, f func(key string, value interface{})) {
+	for i := 0; i < len(keysAndValues); i++ {
+		key, ok := keysAndValues[i].(string)
+		if !ok || i+1 == len(keysAndValues) {
+			f("!BADKEY", keysAndValues[i])
+			continue
+		}
+		f(key, keysAndValues[i+1])
+		i++
+	}
+}
+
+// fieldValue returns the value printed for a field, errors and durations as strings
+func fieldValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case *traceError:
+		return x.StackError()
+	case error:
+		return x.Error()
+	case time.Duration:
+		return x.String()
+	case fmt.Stringer:
+		return x.String()
+	}
+	return v
+}
+
+func writeLogfmt(w *bytes.Buffer, keysAndValues []interface{}) {
+	pairs(keysAndValues, func(key string, value interface{}) {
+		w.WriteByte(' ')
+		w.WriteString(logfmtValue(key))
+		w.WriteByte('=')
+		switch v := fieldValue(value).(type) {
+		case string:
+			w.WriteString(logfmtValue(v))
+		case nil:
+			w.WriteString("null")
+		default:
+			w.WriteString(logfmtValue(fmt.Sprint(v)))
+		}
+	})
+}
+
+// logfmtValue quotes a value when it's empty or has spaces, quotes, = or control characters
+func logfmtValue(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, c := range s {
+		if c == '=' || c == '"' || c == '\\' || unicode.IsSpace(c) || !unicode.IsPrint(c) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+func writeJSON(w *bytes.Buffer, keysAndValues []interface{}) {
+	w.WriteByte('{')
+	first := true
+	pairs(keysAndValues, func(key string, value interface{}) {
+		if !first {
+			w.WriteByte(',')
+		}
+		first = false
+		k, _ := json.Marshal(key)
+		w.Write(k)
+		w.WriteByte(':')
+		v, err := json.Marshal(fieldValue(value))
+		if err != nil {
+			v, _ = json.Marshal(fmt.Sprint(value))
+		}
+		w.Write(v)
+	})
+	w.WriteByte('}')
+}
@@ -0,0 +1,108 @@
+package okhttp
+
+import (
+	"log/slog"
+
+	"github.com/mredencom/okhttp/log"
+)
+
+// Logger prints the debug dumps of the requests, the keys and values are the fields of a log,
+// a *slog.Logger is a Logger
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// NewLogLogger returns a Logger printing with a logger of the log package, its level and format apply
+func NewLogLogger(l *log.Logger) Logger {
+	return &logLogger{l: l.DownLevel(1)}
+}
+
+// logLogger adapts a logger of the log package
+type logLogger struct {
+	l log.Logger
+}
+
+func (a *logLogger) Debug(msg string, keysAndValues ...interface{}) {
+	a.l.Debugw(msg, keysAndValues...)
+}
+
+func (a *logLogger) Info(msg string, keysAndValues ...interface{}) {
+	a.l.Infow(msg, keysAndValues...)
+}
+
+func (a *logLogger) Warn(msg string, keysAndValues ...interface{}) {
+	a.l.Warnw(msg, keysAndValues...)
+}
+
+func (a *logLogger) Error(msg string, keysAndValues ...interface{}) {
+	a.l.Errorw(msg, keysAndValues...)
+}
+
+// nopLogger prints nothing
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+// fieldsLogger adds fields to every log of a Logger
+type fieldsLogger struct {
+	Logger
+	fields []interface{}
+}
+
+func (f *fieldsLogger) with(keysAndValues []interface{}) []interface{} {
+	return append(append(make([]interface{}, 0, len(f.fields)+len(keysAndValues)), f.fields...), keysAndValues...)
+}
+
+func (f *fieldsLogger) Debug(msg string, keysAndValues ...interface{}) {
+	f.Logger.Debug(msg, f.with(keysAndValues)...)
+}
+
+func (f *fieldsLogger) Info(msg string, keysAndValues ...interface{}) {
+	f.Logger.Info(msg, f.with(keysAndValues)...)
+}
+
+func (f *fieldsLogger) Warn(msg string, keysAndValues ...interface{}) {
+	f.Logger.Warn(msg, f.with(keysAndValues)...)
+}
+
+func (f *fieldsLogger) Error(msg string, keysAndValues ...interface{}) {
+	f.Logger.Error(msg, f.with(keysAndValues)...)
+}
+
+// withTraceID returns a logger printing the trace id of a call,
+// the log package prints it as the request id of its prefix
+func withTraceID(l Logger, traceID string) Logger {
+	switch t := l.(type) {
+	case *logLogger:
+		return &logLogger{l: t.l.WithReqID(traceID)}
+	case *slog.Logger:
+		return t.With("trace_id", traceID)
+	case nopLogger:
+		return l
+	}
+	return &fieldsLogger{Logger: l, fields: []interface{}{"trace_id", traceID}}
+}
+
+// SetLogger set the logger of the debug dumps, nil prints nothing
+func (r *Request) SetLogger(l Logger) *Request {
+	if l == nil {
+		l = nopLogger{}
+	}
+	r.l = l
+	return r
+}
+
+// SetLogger set the logger of every request, nil prints nothing
+func (c *Client) SetLogger(l Logger) *Client {
+	if l == nil {
+		l = nopLogger{}
+	}
+	c.logger = l
+	return c
+}
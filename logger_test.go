@@ -0,0 +1,51 @@
+package okhttp
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mredencom/okhttp/log"
+)
+
+func Test_SetLogger(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	buf := &bytes.Buffer{}
+	client := NewClient().SetLogger(slog.New(slog.NewJSONHandler(buf, nil))).SetTracer(NewTracer(nil))
+	req, _ := client.Get(ts.URL)
+	resp, err := req.SetDebug(true).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf(`the request and the response should be logged, "%s" given`, buf.String())
+	}
+	if !strings.Contains(lines[0], `"method":"GET"`) || !strings.Contains(lines[0], `"trace_id":"`+resp.TraceID()+`"`) {
+		t.Errorf(`the request log should have the method and the trace id, "%s" given`, lines[0])
+	}
+	if !strings.Contains(lines[1], `"status":200`) {
+		t.Errorf(`the response log should have the status, "%s" given`, lines[1])
+	}
+
+	buf.Reset()
+	req, _ = client.Get(ts.URL)
+	resp, _ = req.SetLogger(NewLogLogger(log.NewLoggerEx(buf))).SetDebug(true).Do()
+	if !strings.Contains(buf.String(), "]["+resp.TraceID()+"][INFO] GET / HTTP/1.1") {
+		t.Errorf(`the log package should print the trace id in its prefix, "%s" given`, buf.String())
+	}
+
+	buf.Reset()
+	req, _ = client.Get(ts.URL)
+	req.SetLogger(nil).SetDebug(true).Do()
+	if buf.Len() != 0 {
+		t.Errorf(`a nil logger should print nothing, "%s" given`, buf.String())
+	}
+}
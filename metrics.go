@@ -0,0 +1,356 @@
+package okhttp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds in seconds of the request duration histogram
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultSizeBuckets are the upper bounds in bytes of the body size histograms
+var DefaultSizeBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304}
+
+// OtherRoute is the route label of the requests without a path template
+const OtherRoute = "other"
+
+// Metrics measures every call of the clients using it and exposes the measures in the Prometheus text format,
+// the calls are labeled by method, host, route template and status class like 2xx, error when it failed
+type Metrics struct {
+	requests     *metricVec
+	inFlight     *metricVec
+	duration     *metricVec
+	requestSize  *metricVec
+	responseSize *metricVec
+	families     []*metricVec
+}
+
+// NewMetrics created a collector, the metric names start with namespace, okhttp when it's empty
+func NewMetrics(namespace string) *Metrics {
+	if namespace == "" {
+		namespace = "okhttp"
+	}
+	name := func(s string) string {
+		return namespace + "_http_client_" + s
+	}
+	m := &Metrics{
+		requests:     newMetricVec(name("requests_total"), "Requests sent by the client.", "counter", nil, "method", "host", "route", "status"),
+		inFlight:     newMetricVec(name("requests_in_flight"), "Requests waiting for their response.", "gauge", nil, "method", "host", "route"),
+		duration:     newMetricVec(name("request_duration_seconds"), "Time from the start to the end of the requests.", "histogram", DefaultLatencyBuckets, "method", "host", "route", "status"),
+		requestSize:  newMetricVec(name("request_size_bytes"), "Size of the request bodies.", "histogram", DefaultSizeBuckets, "method", "host", "route"),
+		responseSize: newMetricVec(name("response_size_bytes"), "Size of the response bodies.", "histogram", DefaultSizeBuckets, "method", "host", "route", "status"),
+	}
+	m.families = []*metricVec{m.requests, m.inFlight, m.duration, m.requestSize, m.responseSize}
+	return m
+}
+
+// SetLatencyBuckets set the upper bounds in seconds of the duration histogram, before any call is measured
+func (m *Metrics) SetLatencyBuckets(buckets []float64) *Metrics {
+	m.duration.setBuckets(buckets)
+	return m
+}
+
+// SetSizeBuckets set the upper bounds in bytes of the body size histograms, before any call is measured
+func (m *Metrics) SetSizeBuckets(buckets []float64) *Metrics {
+	m.requestSize.setBuckets(buckets)
+	m.responseSize.setBuckets(buckets)
+	return m
+}
+
+// Listener returns the factory measuring the calls, it's added to a client by SetMetrics
+func (m *Metrics) Listener() EventListenerFactory {
+	return func(r *Request) EventListener {
+		route := r.pathTemplate
+		if route == "" {
+			route = OtherRoute
+		}
+		return &metricsListener{m: m, method: r.method, host: r.url.Host, route: route}
+	}
+}
+
+// SetMetrics measures every call of the client with m
+func (c *Client) SetMetrics(m *Metrics) *Client {
+	return c.AddEventListener(m.Listener())
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, f := range m.Gather() {
+		f.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// MetricFamily is a metric with all its label values, it bridges the collector to another registry
+type MetricFamily struct {
+	Name string
+	Help string
+	// Type is counter, gauge or histogram
+	Type    string
+	Metrics []Metric
+}
+
+// Metric is the value of a metric for a set of label values
+type Metric struct {
+	Labels map[string]string
+	// Value is the value of a counter or a gauge
+	Value float64
+	// Buckets are the cumulative counts of a histogram, the last upper bound is +Inf
+	Buckets []Bucket
+	Count   uint64
+	Sum     float64
+}
+
+// Bucket is the number of observations less than or equal to an upper bound
+type Bucket struct {
+	UpperBound float64
+	Count      uint64
+}
+
+// Gather returns a snapshot of the metrics sorted by name and label values
+func (m *Metrics) Gather() []MetricFamily {
+	families := make([]MetricFamily, 0, len(m.families))
+	for _, v := range m.families {
+		families = append(families, v.gather())
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].Name < families[j].Name })
+	return families
+}
+
+// metricsListener measures one call
+type metricsListener struct {
+	NopEventListener
+	m           *Metrics
+	method      string
+	host        string
+	route       string
+	start       time.Time
+	status      string
+	requestSize int64
+	read        int64
+}
+
+func (l *metricsListener) CallStart(req *http.Request) {
+	l.start = time.Now()
+	if req.ContentLength > 0 {
+		l.requestSize = req.ContentLength
+	}
+	l.m.inFlight.add(1, l.method, l.host, l.route)
+}
+
+func (l *metricsListener) ResponseHeadersEnd(resp *http.Response) {
+	l.status = statusClass(resp.StatusCode)
+}
+
+func (l *metricsListener) ResponseBodyEnd(n int64) {
+	l.read = n
+}
+
+func (l *metricsListener) CallEnd(t Timings) {
+	l.done(t.Total, l.status)
+}
+
+func (l *metricsListener) CallFailed(error) {
+	l.done(time.Since(l.start), "error")
+}
+
+func (l *metricsListener) done(d time.Duration, status string) {
+	if l.start.IsZero() {
+		return
+	}
+	m := l.m
+	m.inFlight.add(-1, l.method, l.host, l.route)
+	m.requests.add(1, l.method, l.host, l.route, status)
+	m.duration.observe(d.Seconds(), l.method, l.host, l.route, status)
+	m.requestSize.observe(float64(l.requestSize), l.method, l.host, l.route)
+	if status != "error" {
+		m.responseSize.observe(float64(l.read), l.method, l.host, l.route, status)
+	}
+}
+
+// statusClass returns the class of a status code like 2xx
+func statusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// metricVec is a metric family keyed by its label values
+type metricVec struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	value  float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newMetricVec(name, help, typ string, buckets []float64, labels ...string) *metricVec {
+	v := &metricVec{name: name, help: help, typ: typ, labels: labels, series: map[string]*series{}}
+	v.setBuckets(buckets)
+	return v
+}
+
+func (v *metricVec) setBuckets(buckets []float64) {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	v.mu.Lock()
+	v.buckets = b
+	v.mu.Unlock()
+}
+
+// get returns the series of label values, the lock must be held
+func (v *metricVec) get(values []string) *series {
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{values: values, counts: make([]uint64, len(v.buckets))}
+		v.series[key] = s
+	}
+	return s
+}
+
+func (v *metricVec) add(delta float64, values ...string) {
+	v.mu.Lock()
+	v.get(values).value += delta
+	v.mu.Unlock()
+}
+
+func (v *metricVec) observe(x float64, values ...string) {
+	v.mu.Lock()
+	s := v.get(values)
+	for i, b := range v.buckets {
+		if x <= b && i < len(s.counts) {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += x
+	v.mu.Unlock()
+}
+
+func (v *metricVec) gather() MetricFamily {
+	f := MetricFamily{Name: v.name, Help: v.help, Type: v.typ}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := v.series[k]
+		m := Metric{Labels: map[string]string{}, Value: s.value, Count: s.count, Sum: s.sum}
+		for i, l := range v.labels {
+			m.Labels[l] = s.values[i]
+		}
+		if v.typ == "histogram" {
+			for i, b := range v.buckets {
+				if i < len(s.counts) {
+					m.Buckets = append(m.Buckets, Bucket{UpperBound: b, Count: s.counts[i]})
+				}
+			}
+			m.Buckets = append(m.Buckets, Bucket{UpperBound: math.Inf(1), Count: s.count})
+		}
+		f.Metrics = append(f.Metrics, m)
+	}
+	return f
+}
+
+// write writes the family in the Prometheus text format
+func (f MetricFamily) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.Name, escapeHelp(f.Help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.Name, f.Type)
+	for _, m := range f.Metrics {
+		names := make([]string, 0, len(m.Labels))
+		for k := range m.Labels {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		labels := make([]string, 0, len(names)+1)
+		for _, k := range names {
+			labels = append(labels, k+`="`+escapeLabel(m.Labels[k])+`"`)
+		}
+		if f.Type != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", f.Name, labelSet(labels), formatFloat(m.Value))
+			continue
+		}
+		for _, b := range m.Buckets {
+			le := `le="` + formatFloat(b.UpperBound) + `"`
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.Name, labelSet(append(labels, le)), b.Count)
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.Name, labelSet(labels), formatFloat(m.Sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.Name, labelSet(labels), m.Count)
+	}
+}
+
+func labelSet(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+// countingWriter counts the bytes written
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
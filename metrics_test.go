@@ -0,0 +1,61 @@
+package okhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Metrics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/404") {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	metrics := NewMetrics("")
+	client := NewClient().SetMetrics(metrics)
+	for _, id := range []string{"1", "2", "404"} {
+		req, _ := client.Get(ts.URL)
+		if _, err := req.SetPathTemplate("/users/{id}", map[string]interface{}{"id": id}).Do(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, _ := client.Post(ts.URL)
+	if _, err := req.SetBody(strings.NewReader("body")).Do(); err != nil {
+		t.Fatal(err)
+	}
+	req, _ = client.Get("http://127.0.0.1:1")
+	req.Do()
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf(`Content-Type should be the Prometheus text format, "%s" given`, ct)
+	}
+	host := strings.TrimPrefix(ts.URL, "http://")
+	out := rec.Body.String()
+	for _, line := range []string{
+		`# TYPE okhttp_http_client_requests_total counter`,
+		`okhttp_http_client_requests_total{host="` + host + `",method="GET",route="/users/{id}",status="2xx"} 2`,
+		`okhttp_http_client_requests_total{host="` + host + `",method="GET",route="/users/{id}",status="4xx"} 1`,
+		`okhttp_http_client_requests_total{host="` + host + `",method="POST",route="other",status="2xx"} 1`,
+		`okhttp_http_client_requests_total{host="127.0.0.1:1",method="GET",route="other",status="error"} 1`,
+		`okhttp_http_client_requests_in_flight{host="` + host + `",method="GET",route="/users/{id}"} 0`,
+		`okhttp_http_client_request_duration_seconds_bucket{host="` + host + `",method="GET",route="/users/{id}",status="2xx",le="+Inf"} 2`,
+		`okhttp_http_client_request_size_bytes_bucket{host="` + host + `",method="POST",route="other",le="64"} 1`,
+		`okhttp_http_client_response_size_bytes_sum{host="` + host + `",method="GET",route="/users/{id}",status="2xx"} 10`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf(`metrics should contain "%s"`, line)
+		}
+	}
+
+	families := metrics.Gather()
+	if len(families) != 5 || families[0].Name != "okhttp_http_client_request_duration_seconds" {
+		t.Errorf(`Gather should return 5 families sorted by name, %d given`, len(families))
+	}
+}
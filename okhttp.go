@@ -41,8 +41,7 @@ func NewRequest(method, uri string) (r *Request, err error) {
 		method = http.MethodConnect
 		break
 	default:
-		err = NoMatchHttpMethod
-		break
+		return nil, NoMatchHttpMethod
 	}
 	// parse url
 	parse, err := url.Parse(uri)
@@ -59,7 +58,7 @@ func NewRequest(method, uri string) (r *Request, err error) {
 		allowRedirect: true,
 		debug:         false,
 		isPrintBody:   false,
-		l:             log.NewLogger(0),
+		l:             NewLogLogger(log.NewLogger(0)),
 	}
 	return
 }
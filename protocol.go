@@ -0,0 +1,251 @@
+package okhttp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// Protocol selects the http version spoken by a request
+type Protocol int
+
+const (
+	// ProtocolAuto speaks HTTP/2 over TLS when the server supports it, HTTP/1.1 otherwise
+	ProtocolAuto Protocol = iota
+	// ProtocolHTTP1 speaks HTTP/1.1 only
+	ProtocolHTTP1
+	// ProtocolHTTP2 speaks HTTP/2 over TLS only, failing with H2NotNegotiated when the server doesn't select h2
+	ProtocolHTTP2
+	// ProtocolH2C speaks HTTP/2 without TLS, assuming the server supports it (prior knowledge),
+	// it can't go through a proxy
+	ProtocolH2C
+	// ProtocolH2CUpgrade asks the server to switch to HTTP/2 without TLS with an HTTP/1.1 Upgrade,
+	// and keeps HTTP/1.1 when it refuses. Every request opens its own connection, closed with the body,
+	// and it can't go through a proxy
+	ProtocolH2CUpgrade
+)
+
+// String returns the name of the protocol
+func (p Protocol) String() string {
+	switch p {
+	case ProtocolHTTP1:
+		return "http/1.1"
+	case ProtocolHTTP2:
+		return "h2"
+	case ProtocolH2C:
+		return "h2c"
+	case ProtocolH2CUpgrade:
+		return "h2c-upgrade"
+	}
+	return "auto"
+}
+
+// H2NotNegotiated is returned by ProtocolHTTP2 when the server doesn't select h2 with ALPN
+var H2NotNegotiated = errors.New("server did not negotiate h2")
+
+// ProxyNotSupported is returned when a proxy is set on a request speaking h2c
+var ProxyNotSupported = errors.New("proxy is not supported by protocol")
+
+// HTTP2Options are the knobs of HTTP/2 connections
+type HTTP2Options struct {
+	// ReadIdleTimeout sends a ping when no frame is received for it, zero disables the health check,
+	// it's ignored by ProtocolH2CUpgrade whose connections serve a single request
+	ReadIdleTimeout time.Duration
+	// PingTimeout closes the connection when a ping is not answered in time, default 15s,
+	// it's ignored by ProtocolH2CUpgrade
+	PingTimeout time.Duration
+	// WriteByteTimeout closes the connection when no data can be written for it
+	WriteByteTimeout time.Duration
+	// MaxHeaderListSize is the http2 SETTINGS_MAX_HEADER_LIST_SIZE, zero means the default
+	MaxHeaderListSize uint32
+	// StrictMaxConcurrentStreams waits for a stream instead of dialing a new connection
+	StrictMaxConcurrentStreams bool
+}
+
+func (o *HTTP2Options) apply(t *http2.Transport) {
+	if o == nil {
+		return
+	}
+	t.ReadIdleTimeout = o.ReadIdleTimeout
+	t.PingTimeout = o.PingTimeout
+	t.WriteByteTimeout = o.WriteByteTimeout
+	t.MaxHeaderListSize = o.MaxHeaderListSize
+	t.StrictMaxConcurrentStreams = o.StrictMaxConcurrentStreams
+}
+
+// transport create the round tripper speaking the protocol of the request
+func (r *Request) transport() (http.RoundTripper, error) {
+	dialContext := r.dialContext
+	if dialContext == nil {
+		dialContext = defaultDialContext
+	}
+
+	t1 := &http.Transport{
+		// 设置代理
+		Proxy:                 r.proxy,
+		DialContext:           dialContext,
+		TLSClientConfig:       r.tlsConfig.Clone(),
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	switch r.protocol {
+	case ProtocolHTTP1:
+		t1.ForceAttemptHTTP2 = false
+		t1.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		return t1, nil
+	case ProtocolHTTP2:
+		// t1 dials with the context of the request and tunnels through the proxy,
+		// its TLS connections are handed to http2 when the server selects h2
+		t2, err := http2.ConfigureTransports(t1)
+		if err != nil {
+			return nil, err
+		}
+		r.http2Options.apply(t2)
+		t1.TLSClientConfig.NextProtos = []string{http2.NextProtoTLS}
+		verify := t1.TLSClientConfig.VerifyConnection
+		t1.TLSClientConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			if state.NegotiatedProtocol != http2.NextProtoTLS {
+				return fmt.Errorf("%w: %q negotiated", H2NotNegotiated, state.NegotiatedProtocol)
+			}
+			if verify != nil {
+				return verify(state)
+			}
+			return nil
+		}
+		return &h2Transport{t1}, nil
+	case ProtocolH2C:
+		if r.proxy != nil {
+			return nil, fmt.Errorf("%w: %s", ProxyNotSupported, r.protocol)
+		}
+		t2 := &http2.Transport{AllowHTTP: true}
+		pool := &h2cConnPool{t: t2, dial: dialContext, conns: map[string][]*http2.ClientConn{}}
+		t2.ConnPool = pool
+		r.http2Options.apply(t2)
+		return &h2cTransport{Transport: t2, pool: pool}, nil
+	case ProtocolH2CUpgrade:
+		if r.proxy != nil {
+			return nil, fmt.Errorf("%w: %s", ProxyNotSupported, r.protocol)
+		}
+		return &h2cUpgradeTransport{
+			dial:    dialContext,
+			tls:     t1,
+			options: r.http2Options,
+		}, nil
+	}
+
+	if r.http2Options != nil {
+		t2, err := http2.ConfigureTransports(t1)
+		if err != nil {
+			return nil, err
+		}
+		r.http2Options.apply(t2)
+	}
+	return t1, nil
+}
+
+// h2Transport sends the https requests of ProtocolHTTP2, refusing the plain http ones
+type h2Transport struct {
+	*http.Transport
+}
+
+func (t *h2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("protocol h2 needs https, %s given, use ProtocolH2C", req.URL.Scheme)
+	}
+	return t.Transport.RoundTrip(req)
+}
+
+// h2cTransport sends the requests of ProtocolH2C, its idle connections are closed by its pool
+// as http2 only closes the ones of its own pool
+type h2cTransport struct {
+	*http2.Transport
+	pool *h2cConnPool
+}
+
+func (t *h2cTransport) CloseIdleConnections() {
+	t.pool.closeIdle()
+}
+
+// h2cConnPool shares the h2c connections by address, dialing them with the context of the request
+type h2cConnPool struct {
+	t    *http2.Transport
+	dial DialContextFunc
+
+	mu    sync.Mutex
+	conns map[string][]*http2.ClientConn
+}
+
+func (p *h2cConnPool) GetClientConn(req *http.Request, addr string) (*http2.ClientConn, error) {
+	p.mu.Lock()
+	for _, cc := range p.conns[addr] {
+		if cc.ReserveNewRequest() {
+			p.mu.Unlock()
+			return cc, nil
+		}
+	}
+	p.mu.Unlock()
+
+	conn, err := p.dial(req.Context(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := p.t.NewClientConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !cc.ReserveNewRequest() {
+		cc.Close()
+		return nil, errors.New("h2c connection refused the request")
+	}
+	p.mu.Lock()
+	p.conns[addr] = append(p.conns[addr], cc)
+	p.mu.Unlock()
+	return cc, nil
+}
+
+func (p *h2cConnPool) MarkDead(cc *http2.ClientConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr, conns := range p.conns {
+		for i, c := range conns {
+			if c == cc {
+				p.conns[addr] = append(conns[:i:i], conns[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// closeIdle closes the connections without stream
+func (p *h2cConnPool) closeIdle() {
+	var idle []*http2.ClientConn
+	p.mu.Lock()
+	for addr, conns := range p.conns {
+		kept := conns[:0]
+		for _, cc := range conns {
+			if st := cc.State(); st.StreamsActive == 0 && st.StreamsReserved == 0 && st.StreamsPending == 0 {
+				idle = append(idle, cc)
+				continue
+			}
+			kept = append(kept, cc)
+		}
+		p.conns[addr] = kept
+	}
+	p.mu.Unlock()
+	for _, cc := range idle {
+		cc.Close()
+	}
+}
@@ -0,0 +1,239 @@
+package okhttp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func newH2CServer() *httptest.Server {
+	return httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+		w.Write([]byte("hello " + r.Proto))
+	}), &http2.Server{}))
+}
+
+func Test_ProtocolH2C(t *testing.T) {
+	ts := newH2CServer()
+	defer ts.Close()
+
+	for _, p := range []Protocol{ProtocolH2C, ProtocolH2CUpgrade} {
+		req, _ := Get(ts.URL)
+		resp, err := req.SetProtocol(p).Do()
+		if err != nil {
+			t.Fatal(p, err)
+		}
+		if resp.Protocol() != "HTTP/2.0" {
+			t.Errorf(`%s protocol should be "%s", "%s" given`, p, "HTTP/2.0", resp.Protocol())
+		}
+		if resp.String() != "hello HTTP/2.0" {
+			t.Errorf(`%s body should be "%s", "%s" given`, p, "hello HTTP/2.0", resp.String())
+		}
+	}
+}
+
+func Test_ProtocolH2CUpgradeRefused(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello " + r.Proto))
+	}))
+	defer ts.Close()
+
+	req, _ := Get(ts.URL)
+	resp, err := req.SetProtocol(ProtocolH2CUpgrade).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Protocol() != "HTTP/1.1" || resp.String() != "hello HTTP/1.1" {
+		t.Errorf(`Refused upgrade should stay on HTTP/1.1, %s "%s" given`, resp.Protocol(), resp.String())
+	}
+}
+
+func Test_ProtocolTLS(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	client := NewClient().SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	cases := map[Protocol]string{
+		ProtocolAuto:  "HTTP/2.0",
+		ProtocolHTTP1: "HTTP/1.1",
+		ProtocolHTTP2: "HTTP/2.0",
+	}
+	for p, want := range cases {
+		req, _ := client.Get(ts.URL)
+		resp, err := req.SetProtocol(p).SetHTTP2Options(HTTP2Options{
+			ReadIdleTimeout:   time.Second,
+			MaxHeaderListSize: 1 << 16,
+		}).Do()
+		if err != nil {
+			t.Fatal(p, err)
+		}
+		if resp.Protocol() != want || resp.String() != want {
+			t.Errorf(`%s protocol should be "%s", "%s" given`, p, want, resp.Protocol())
+		}
+	}
+}
+
+func Test_ProtocolHTTP2NotNegotiated(t *testing.T) {
+	// the listener has no ALPN, the handshake succeeds without selecting a protocol
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	ts.StartTLS()
+	defer ts.Close()
+	cfg := &tls.Config{Certificates: ts.TLS.Certificates}
+	l, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go http.Serve(l, ts.Config.Handler)
+
+	req, _ := NewClient().SetTLSConfig(&tls.Config{InsecureSkipVerify: true}).Get("https://" + l.Addr().String())
+	_, err = req.SetProtocol(ProtocolHTTP2).Do()
+	if !errors.Is(err, H2NotNegotiated) {
+		t.Errorf(`Error should be "%v", "%v" given`, H2NotNegotiated, err)
+	}
+}
+
+type ctxKey struct{}
+
+func Test_ProtocolDialContext(t *testing.T) {
+	tlsServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+	h2cServer := newH2CServer()
+	defer h2cServer.Close()
+
+	for p, url := range map[Protocol]string{ProtocolHTTP2: tlsServer.URL, ProtocolH2C: h2cServer.URL} {
+		var given interface{}
+		dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+			given = ctx.Value(ctxKey{})
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		}
+		req, _ := NewClient().SetTLSConfig(&tls.Config{InsecureSkipVerify: true}).Get(url)
+		resp, err := req.SetProtocol(p).SetDialContext(dial).SetContext(context.WithValue(context.Background(), ctxKey{}, "call")).Do()
+		if err != nil {
+			t.Fatal(p, err)
+		}
+		if resp.String() != "HTTP/2.0" && resp.String() != "hello HTTP/2.0" {
+			t.Errorf(`%s protocol should be "%s", "%s" given`, p, "HTTP/2.0", resp.String())
+		}
+		if given != "call" {
+			t.Errorf(`%s should dial with the context of the request, "%v" given`, p, given)
+		}
+	}
+}
+
+// trackedConn reports its close to a counter of open connections
+type trackedConn struct {
+	net.Conn
+	open *int32
+	once sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() { atomic.AddInt32(c.open, -1) })
+	return c.Conn.Close()
+}
+
+func Test_ProtocolConnectionLeak(t *testing.T) {
+	ts := newH2CServer()
+	defer ts.Close()
+
+	for _, p := range []Protocol{ProtocolHTTP1, ProtocolH2C, ProtocolH2CUpgrade} {
+		var open, dialed int32
+		dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			atomic.AddInt32(&dialed, 1)
+			atomic.AddInt32(&open, 1)
+			return &trackedConn{Conn: conn, open: &open}, nil
+		}
+		for i := 0; i < 20; i++ {
+			req, _ := Get(ts.URL)
+			if _, err := req.SetProtocol(p).SetDialContext(dial).Do(); err != nil {
+				t.Fatal(p, err)
+			}
+		}
+		if atomic.LoadInt32(&dialed) != 20 || atomic.LoadInt32(&open) != 0 {
+			t.Errorf(`%s connections should be closed with their response, %d of %d open`, p, open, dialed)
+		}
+	}
+}
+
+func Test_ProtocolHTTP2Proxy(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	tunnels := 0
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		tunnels++
+		w.WriteHeader(http.StatusOK)
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		go func() {
+			io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+		io.Copy(conn, upstream)
+		conn.Close()
+	}))
+	defer proxy.Close()
+
+	req, _ := NewClient().SetTLSConfig(&tls.Config{InsecureSkipVerify: true}).Get(ts.URL)
+	resp, err := req.SetProtocol(ProtocolHTTP2).SetProxy(proxy.URL).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.String() != "HTTP/2.0" {
+		t.Errorf(`Protocol should be "%s", "%s" given`, "HTTP/2.0", resp.String())
+	}
+	if tunnels != 1 {
+		t.Errorf(`Tunnels should be "%d", "%d" given`, 1, tunnels)
+	}
+}
+
+func Test_ProtocolH2CProxy(t *testing.T) {
+	ts := newH2CServer()
+	defer ts.Close()
+
+	for _, p := range []Protocol{ProtocolH2C, ProtocolH2CUpgrade} {
+		req, _ := Get(ts.URL)
+		_, err := req.SetProtocol(p).SetProxy("http://127.0.0.1:1").Do()
+		if !errors.Is(err, ProxyNotSupported) {
+			t.Errorf(`%s error should be "%v", "%v" given`, p, ProxyNotSupported, err)
+		}
+	}
+}
@@ -0,0 +1,452 @@
+package okhttp
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// NoAvailableProxy is returned when every proxy of a pool is ejected
+var NoAvailableProxy = errors.New("no available proxy in pool")
+
+// ProxyStrategy decides how a ProxyPool picks the next proxy
+type ProxyStrategy int
+
+const (
+	// RoundRobin picks proxies in turn
+	RoundRobin ProxyStrategy = iota
+	// RandomProxy picks a random proxy
+	RandomProxy
+	// LeastFailures picks the proxy with the fewest failures
+	LeastFailures
+)
+
+// StickyMode decides whether a ProxyPool pins requests to a proxy
+type StickyMode int
+
+const (
+	// StickyNone picks a proxy for every request
+	StickyNone StickyMode = iota
+	// StickyHost pins every target host to one proxy
+	StickyHost
+	// StickySession pins every session set by Request.SetProxySession to one proxy
+	StickySession
+)
+
+// DefaultMaxPinned is the number of hosts or sessions a sticky pool remembers
+var DefaultMaxPinned = 10000
+
+// DefaultPinTTL is how long a sticky pool remembers a host or a session which is not used
+var DefaultPinTTL = 30 * time.Minute
+
+// ProxyStats is a snapshot of the stats of one proxy
+type ProxyStats struct {
+	URL                 string
+	Successes           int64
+	Failures            int64
+	ConsecutiveFailures int
+	LastLatency         time.Duration
+	AvgLatency          time.Duration
+	Ejected             bool
+	EjectedUntil        time.Time
+}
+
+type proxyEntry struct {
+	url          *url.URL
+	successes    int64
+	failures     int64
+	consecutive  int
+	lastLatency  time.Duration
+	totalLatency time.Duration
+	ejectedUntil time.Time
+}
+
+func (e *proxyEntry) available(now time.Time) bool {
+	return !now.Before(e.ejectedUntil)
+}
+
+// ProxyPool spreads requests across several proxies
+type ProxyPool struct {
+	mu       sync.Mutex
+	proxies  []*proxyEntry
+	strategy ProxyStrategy
+	sticky   StickyMode
+	pinned   map[string]*list.Element
+	pins     *list.List
+	next     int
+
+	maxPinned int
+	pinTTL    time.Duration
+	rand      *rand.Rand
+
+	maxFails int
+	coolOff  time.Duration
+
+	checkURL      string
+	checkInterval time.Duration
+	checkTimeout  time.Duration
+	stop          chan struct{}
+}
+
+// NewProxyPool created a proxy pool
+func NewProxyPool(proxyURLs ...string) (*ProxyPool, error) {
+	p := &ProxyPool{
+		pinned:        map[string]*list.Element{},
+		pins:          list.New(),
+		maxPinned:     DefaultMaxPinned,
+		pinTTL:        DefaultPinTTL,
+		rand:          rand.New(rand.NewSource(time.Now().UnixNano())),
+		maxFails:      3,
+		coolOff:       30 * time.Second,
+		checkInterval: 30 * time.Second,
+		checkTimeout:  5 * time.Second,
+	}
+	for _, proxyURL := range proxyURLs {
+		if err := p.Add(proxyURL); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Add add a proxy to the pool
+func (p *ProxyPool) Add(proxyURL string) error {
+	parse, err := url.Parse(proxyURL)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.proxies = append(p.proxies, &proxyEntry{url: parse})
+	p.mu.Unlock()
+	return nil
+}
+
+// SetStrategy set the strategy used to pick proxies
+func (p *ProxyPool) SetStrategy(s ProxyStrategy) *ProxyPool {
+	p.mu.Lock()
+	p.strategy = s
+	p.mu.Unlock()
+	return p
+}
+
+// SetSticky set the sticky assignment mode
+func (p *ProxyPool) SetSticky(m StickyMode) *ProxyPool {
+	p.mu.Lock()
+	p.sticky = m
+	p.pinned = map[string]*list.Element{}
+	p.pins.Init()
+	p.mu.Unlock()
+	return p
+}
+
+// SetStickyLimit set how many hosts or sessions a sticky pool remembers and how long an unused one is kept,
+// the least recently used one is forgotten first
+func (p *ProxyPool) SetStickyLimit(maxPinned int, ttl time.Duration) *ProxyPool {
+	p.mu.Lock()
+	p.maxPinned = maxPinned
+	p.pinTTL = ttl
+	p.mu.Unlock()
+	return p
+}
+
+// SetEjection set how many consecutive failures eject a proxy and how long it stays out
+func (p *ProxyPool) SetEjection(maxFails int, coolOff time.Duration) *ProxyPool {
+	p.mu.Lock()
+	p.maxFails = maxFails
+	p.coolOff = coolOff
+	p.mu.Unlock()
+	return p
+}
+
+// SetHealthCheck set the url requested through every proxy by the background health check
+func (p *ProxyPool) SetHealthCheck(checkURL string, interval, timeout time.Duration) *ProxyPool {
+	p.mu.Lock()
+	p.checkURL = checkURL
+	if interval > 0 {
+		p.checkInterval = interval
+	}
+	if timeout > 0 {
+		p.checkTimeout = timeout
+	}
+	p.mu.Unlock()
+	return p
+}
+
+// Start runs the health check in background until Stop is called
+func (p *ProxyPool) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil || p.checkURL == "" {
+		return
+	}
+	p.stop = make(chan struct{})
+	go p.healthLoop(p.stop, p.checkInterval)
+}
+
+// Stop stops the background health check
+func (p *ProxyPool) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+	}
+}
+
+func (p *ProxyPool) healthLoop(stop chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.CheckHealth()
+		}
+	}
+}
+
+// CheckHealth requests the health check url through every proxy once
+func (p *ProxyPool) CheckHealth() {
+	p.mu.Lock()
+	checkURL, timeout := p.checkURL, p.checkTimeout
+	proxies := make([]*url.URL, 0, len(p.proxies))
+	for _, e := range p.proxies {
+		proxies = append(proxies, e.url)
+	}
+	p.mu.Unlock()
+	if checkURL == "" {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, proxy := range proxies {
+		wg.Add(1)
+		go func(proxy *url.URL) {
+			defer wg.Done()
+			client := &http.Client{
+				Transport: &http.Transport{Proxy: http.ProxyURL(proxy)},
+				Timeout:   timeout,
+			}
+			defer client.CloseIdleConnections()
+			start := time.Now()
+			response, err := client.Get(checkURL)
+			if err == nil {
+				response.Body.Close()
+				if response.StatusCode >= http.StatusInternalServerError {
+					err = errors.New(response.Status)
+				}
+			}
+			p.Report(proxy, time.Since(start), proxyError(response, err))
+		}(proxy)
+	}
+	wg.Wait()
+}
+
+// Proxy returns the proxy for a request, it can be used as http.Transport.Proxy
+func (p *ProxyPool) Proxy(req *http.Request) (*url.URL, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	key := p.stickyKey(req)
+	if key != "" {
+		if e := p.pinnedProxy(key, now); e != nil {
+			p.chosen(req, e)
+			return e.url, nil
+		}
+	}
+
+	e := p.pick(now)
+	if e == nil {
+		return nil, NoAvailableProxy
+	}
+	if key != "" {
+		p.pin(key, e, now)
+	}
+	p.chosen(req, e)
+	return e.url, nil
+}
+
+// pin is a host or a session pinned to a proxy
+type pin struct {
+	key      string
+	entry    *proxyEntry
+	lastUsed time.Time
+}
+
+// pinnedProxy returns the available proxy pinned to key, an expired pin or one of an ejected proxy is removed
+func (p *ProxyPool) pinnedProxy(key string, now time.Time) *proxyEntry {
+	el, ok := p.pinned[key]
+	if !ok {
+		return nil
+	}
+	pn := el.Value.(*pin)
+	if !pn.entry.available(now) || p.pinTTL > 0 && now.Sub(pn.lastUsed) > p.pinTTL {
+		p.unpin(el)
+		return nil
+	}
+	pn.lastUsed = now
+	p.pins.MoveToFront(el)
+	return pn.entry
+}
+
+func (p *ProxyPool) pin(key string, e *proxyEntry, now time.Time) {
+	p.pinned[key] = p.pins.PushFront(&pin{key: key, entry: e, lastUsed: now})
+	for p.maxPinned > 0 && p.pins.Len() > p.maxPinned {
+		p.unpin(p.pins.Back())
+	}
+	// the expired pins are the least recently used ones
+	for el := p.pins.Back(); el != nil && p.pinTTL > 0 && now.Sub(el.Value.(*pin).lastUsed) > p.pinTTL; el = p.pins.Back() {
+		p.unpin(el)
+	}
+}
+
+func (p *ProxyPool) unpin(el *list.Element) {
+	delete(p.pinned, el.Value.(*pin).key)
+	p.pins.Remove(el)
+}
+
+// unpinProxy forgets the hosts and sessions pinned to an ejected proxy
+func (p *ProxyPool) unpinProxy(e *proxyEntry) {
+	for el := p.pins.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*pin).entry == e {
+			p.unpin(el)
+		}
+		el = next
+	}
+}
+
+func (p *ProxyPool) stickyKey(req *http.Request) string {
+	switch p.sticky {
+	case StickyHost:
+		return req.URL.Host
+	case StickySession:
+		session, _ := req.Context().Value(proxySessionKey{}).(string)
+		return session
+	}
+	return ""
+}
+
+func (p *ProxyPool) chosen(req *http.Request, e *proxyEntry) {
+	if c, ok := req.Context().Value(proxyChoiceKey{}).(*proxyChoice); ok {
+		c.url = e.url
+	}
+}
+
+func (p *ProxyPool) pick(now time.Time) *proxyEntry {
+	available := make([]*proxyEntry, 0, len(p.proxies))
+	for _, e := range p.proxies {
+		if e.available(now) {
+			available = append(available, e)
+		}
+	}
+	if len(available) == 0 {
+		return nil
+	}
+
+	switch p.strategy {
+	case RandomProxy:
+		return available[p.rand.Intn(len(available))]
+	case LeastFailures:
+		best := available[0]
+		for _, e := range available[1:] {
+			if e.failures < best.failures {
+				best = e
+			}
+		}
+		return best
+	}
+	e := available[p.next%len(available)]
+	p.next++
+	return e
+}
+
+// Report records the outcome of a request sent through a proxy
+func (p *ProxyPool) Report(proxy *url.URL, latency time.Duration, err error) {
+	if proxy == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, e := range p.proxies {
+		if e.url.String() != proxy.String() {
+			continue
+		}
+		if err == nil {
+			e.successes++
+			e.consecutive = 0
+			e.lastLatency = latency
+			e.totalLatency += latency
+			e.ejectedUntil = time.Time{}
+			return
+		}
+		e.failures++
+		e.consecutive++
+		if p.maxFails > 0 && e.consecutive >= p.maxFails {
+			// a proxy back from cool-off is ejected again by a single failure
+			e.ejectedUntil = time.Now().Add(p.coolOff)
+			p.unpinProxy(e)
+		}
+		return
+	}
+}
+
+// Stats returns the stats of every proxy
+func (p *ProxyPool) Stats() []ProxyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	stats := make([]ProxyStats, 0, len(p.proxies))
+	for _, e := range p.proxies {
+		s := ProxyStats{
+			URL:                 e.url.String(),
+			Successes:           e.successes,
+			Failures:            e.failures,
+			ConsecutiveFailures: e.consecutive,
+			LastLatency:         e.lastLatency,
+			Ejected:             !e.available(now),
+		}
+		if s.Ejected {
+			s.EjectedUntil = e.ejectedUntil
+		}
+		if e.successes > 0 {
+			s.AvgLatency = e.totalLatency / time.Duration(e.successes)
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+type proxySessionKey struct{}
+
+type proxyChoiceKey struct{}
+
+// proxyChoice carries the proxy picked for a request back to Request.Do
+type proxyChoice struct {
+	url *url.URL
+}
+
+// WithProxySession returns a context pinning its requests to one proxy of a StickySession pool
+func WithProxySession(ctx context.Context, session string) context.Context {
+	return context.WithValue(ctx, proxySessionKey{}, session)
+}
+
+// proxyError returns the error to report to a pool for the outcome of a request
+func proxyError(response *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	if response.StatusCode == http.StatusProxyAuthRequired || response.StatusCode == http.StatusBadGateway {
+		return errors.New(response.Status)
+	}
+	return nil
+}
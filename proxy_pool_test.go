@@ -0,0 +1,149 @@
+package okhttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newTestProxy(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proxy", name)
+		w.Write([]byte(r.URL.String()))
+	}))
+}
+
+func Test_ProxyPoolRoundRobin(t *testing.T) {
+	a, b := newTestProxy("a"), newTestProxy("b")
+	defer a.Close()
+	defer b.Close()
+
+	pool, err := NewProxyPool(a.URL, b.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		req, _ := Get("http://example.com/page")
+		resp, err := req.SetProxyPool(pool).Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, resp.GetHeader("X-Proxy"))
+	}
+	if got[0] == got[1] || got[0] != got[2] || got[1] != got[3] {
+		t.Errorf(`Proxies should alternate, %v given`, got)
+	}
+
+	for _, s := range pool.Stats() {
+		if s.Successes != 2 {
+			t.Errorf(`Proxy %s should have 2 successes, %d given`, s.URL, s.Successes)
+		}
+	}
+}
+
+func Test_ProxyPoolSticky(t *testing.T) {
+	a, b := newTestProxy("a"), newTestProxy("b")
+	defer a.Close()
+	defer b.Close()
+
+	pool, _ := NewProxyPool(a.URL, b.URL)
+	pool.SetSticky(StickySession)
+
+	var got []string
+	for i := 0; i < 3; i++ {
+		req, _ := Get("http://example.com/")
+		resp, err := req.SetProxyPool(pool).SetProxySession("user-1").Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, resp.GetHeader("X-Proxy"))
+	}
+	if got[0] != got[1] || got[1] != got[2] {
+		t.Errorf(`Session should stick to one proxy, %v given`, got)
+	}
+}
+
+func Test_ProxyPoolStickyLimit(t *testing.T) {
+	pool, _ := NewProxyPool("http://a.example", "http://b.example")
+	pool.SetSticky(StickyHost).SetStickyLimit(2, time.Hour)
+
+	proxyOf := func(host string) *url.URL {
+		req := httptest.NewRequest(http.MethodGet, "http://"+host+"/", nil)
+		u, err := pool.Proxy(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	for i := 0; i < 10; i++ {
+		proxyOf("host" + string(rune('a'+i)))
+	}
+	if len(pool.pinned) != 2 || pool.pins.Len() != 2 {
+		t.Errorf(`Pinned hosts should be bounded by 2, %d given`, len(pool.pinned))
+	}
+
+	pool.SetStickyLimit(0, 0).SetEjection(1, time.Hour)
+	first := proxyOf("sticky.example")
+	pool.Report(first, time.Millisecond, errors.New("down"))
+	if _, ok := pool.pinned["sticky.example"]; ok {
+		t.Errorf(`Hosts pinned to an ejected proxy should be forgotten`)
+	}
+	if proxyOf("sticky.example").String() == first.String() {
+		t.Errorf(`Host should move to another proxy after an ejection`)
+	}
+}
+
+func Test_ProxyPoolEjection(t *testing.T) {
+	pool, _ := NewProxyPool("http://a.proxy:8080", "http://b.proxy:8080")
+	pool.SetStrategy(LeastFailures).SetEjection(2, 50*time.Millisecond)
+
+	bad, _ := url.Parse("http://a.proxy:8080")
+	pool.Report(bad, 0, errors.New("dial"))
+	pool.Report(bad, 0, errors.New("dial"))
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	for i := 0; i < 3; i++ {
+		proxy, err := pool.Proxy(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proxy.Host != "b.proxy:8080" {
+			t.Errorf(`Ejected proxy should be skipped, %s given`, proxy.Host)
+		}
+	}
+
+	good, _ := url.Parse("http://b.proxy:8080")
+	pool.Report(good, 0, errors.New("dial"))
+	pool.Report(good, 0, errors.New("dial"))
+	if _, err := pool.Proxy(req); err != NoAvailableProxy {
+		t.Errorf(`Error should be %v, %v given`, NoAvailableProxy, err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := pool.Proxy(req); err != nil {
+		t.Errorf(`Proxies should be back after cool-off, %v given`, err)
+	}
+}
+
+func Test_ProxyPoolHealthCheck(t *testing.T) {
+	a := newTestProxy("a")
+	defer a.Close()
+
+	pool, _ := NewProxyPool(a.URL, "http://127.0.0.1:1")
+	pool.SetEjection(1, time.Minute).SetHealthCheck("http://example.com/health", time.Hour, time.Second)
+	pool.CheckHealth()
+
+	for _, s := range pool.Stats() {
+		if s.URL == a.URL && (s.Ejected || s.Successes != 1) {
+			t.Errorf(`Healthy proxy should stay in, %+v given`, s)
+		}
+		if s.URL != a.URL && !s.Ejected {
+			t.Errorf(`Dead proxy should be ejected, %+v given`, s)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package okhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+)
+
+// RedactMask replaces the redacted values
+var RedactMask = "REDACTED"
+
+// DefaultRedactedHeaders are the headers masked by every redactor
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// DefaultRedactBodySize is the number of bytes of a body kept by a new redactor
+var DefaultRedactBodySize = 4096
+
+// Redactor masks the secrets of the requests and responses written to the debug dumps, the logs and the spans:
+// headers, query parameters, form fields and JSON fields selected by a JSONPath, and cuts the long bodies
+type Redactor struct {
+	headers     map[string]bool
+	params      map[string]bool
+	jsonPaths   []*JSONPath
+	maxBodySize int
+	errs        []error
+}
+
+// NewRedactor created a redactor masking the DefaultRedactedHeaders and keeping DefaultRedactBodySize bytes of the bodies
+func NewRedactor() *Redactor {
+	r := &Redactor{headers: map[string]bool{}, params: map[string]bool{}, maxBodySize: DefaultRedactBodySize}
+	return r.AddHeaders(DefaultRedactedHeaders...)
+}
+
+// defaultRedactor is used by the requests without a redactor
+var defaultRedactor = NewRedactor()
+
+// AddHeaders masks the values of headers
+func (r *Redactor) AddHeaders(names ...string) *Redactor {
+	for _, name := range names {
+		r.headers[http.CanonicalHeaderKey(name)] = true
+	}
+	return r
+}
+
+// AddQueryParams masks query parameters and the fields of form bodies, the names are case insensitive
+func (r *Redactor) AddQueryParams(names ...string) *Redactor {
+	for _, name := range names {
+		r.params[strings.ToLower(name)] = true
+	}
+	return r
+}
+
+// AddJSONFields masks the fields of JSON bodies selected by JSONPaths like $.password or $..token,
+// an invalid path is returned by Err
+func (r *Redactor) AddJSONFields(paths ...string) *Redactor {
+	for _, path := range paths {
+		p, err := CompileJSONPath(path)
+		if err != nil {
+			r.errs = append(r.errs, err)
+			continue
+		}
+		r.jsonPaths = append(r.jsonPaths, p)
+	}
+	return r
+}
+
+// SetMaxBodySize set the number of bytes of a body which are kept, 0 keeps the whole body and -1 drops it
+func (r *Redactor) SetMaxBodySize(n int) *Redactor {
+	r.maxBodySize = n
+	return r
+}
+
+// Err returns the invalid JSONPaths
+func (r *Redactor) Err() error {
+	return errors.Join(r.errs...)
+}
+
+// SetRedactor set the redaction policy of every request
+func (c *Client) SetRedactor(r *Redactor) *Client {
+	c.redactor = r
+	return c
+}
+
+// SetRedactor set the redaction policy of the debug dumps, the logs and the spans of the request,
+// nil uses the default policy
+func (r *Request) SetRedactor(redactor *Redactor) *Request {
+	if redactor != nil {
+		if err := redactor.Err(); err != nil {
+			return r.addError(err)
+		}
+	}
+	r.redactor = redactor
+	return r
+}
+
+// getRedactor returns the redactor of the request or the default one
+func (r *Request) getRedactor() *Redactor {
+	if r.redactor == nil {
+		return defaultRedactor
+	}
+	return r.redactor
+}
+
+// Header returns a copy of h with the values of the redacted headers masked
+func (r *Redactor) Header(h http.Header) http.Header {
+	out := h.Clone()
+	for k, vs := range out {
+		if !r.headers[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		masked := make([]string, len(vs))
+		for i := range vs {
+			masked[i] = RedactMask
+		}
+		out[k] = masked
+	}
+	return out
+}
+
+// URL returns the url with the password and the redacted query parameters masked
+func (r *Redactor) URL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	c := *u
+	if _, ok := c.User.Password(); ok {
+		c.User = url.UserPassword(c.User.Username(), RedactMask)
+	}
+	c.RawQuery = r.query(c.RawQuery)
+	return c.String()
+}
+
+// query masks the redacted parameters of a query, keeping its order and encoding
+func (r *Redactor) query(raw string) string {
+	if len(r.params) == 0 || raw == "" {
+		return raw
+	}
+	parts := strings.Split(raw, "&")
+	for i, part := range parts {
+		key, _, _ := strings.Cut(part, "=")
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if r.params[strings.ToLower(key)] {
+			parts[i] = url.QueryEscape(key) + "=" + RedactMask
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// Body returns the body with the redacted fields of a JSON or form body masked, cut to the max body size
+func (r *Redactor) Body(contentType string, body []byte) []byte {
+	if r.maxBodySize < 0 || len(body) == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		body = []byte(r.query(string(body)))
+	case len(r.jsonPaths) > 0 && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")):
+		body = r.json(body)
+	}
+	if r.maxBodySize > 0 && len(body) > r.maxBodySize {
+		cut := len(body) - r.maxBodySize
+		body = append(body[:r.maxBodySize:r.maxBodySize], fmt.Sprintf("... (%d bytes truncated)", cut)...)
+	}
+	return body
+}
+
+// maskJSON masks the fields of a JSON body without cutting it, the body is unchanged without JSON fields
+func (r *Redactor) maskJSON(body []byte) []byte {
+	if len(r.jsonPaths) == 0 || len(body) == 0 {
+		return body
+	}
+	return r.json(body)
+}
+
+// json masks the fields of a JSON body, a body which is not JSON is dropped as it may hide a secret
+func (r *Redactor) json(body []byte) []byte {
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	var doc interface{}
+	if err := d.Decode(&doc); err != nil {
+		return []byte("[invalid JSON body " + RedactMask + "]")
+	}
+	for _, p := range r.jsonPaths {
+		doc = maskJSONPath(p.segments, doc, doc)
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return []byte("[JSON body " + RedactMask + "]")
+	}
+	return out
+}
+
+// maskJSONPath replaces the nodes matched by the segments with the mask
+func maskJSONPath(segments []jsonPathSegment, doc, root interface{}) interface{} {
+	if len(segments) == 0 {
+		return RedactMask
+	}
+	last := segments[len(segments)-1]
+	for _, parent := range evalJSONPath(segments[:len(segments)-1], doc, root) {
+		targets := []interface{}{parent}
+		if last.recursive {
+			targets = descendants(parent, targets)
+		}
+		for _, t := range targets {
+			for _, sel := range last.selectors {
+				maskSelected(sel, t, root)
+			}
+		}
+	}
+	return doc
+}
+
+// maskSelected masks the children of a node selected by sel
+func maskSelected(sel jsonPathSelector, n, root interface{}) {
+	switch v := n.(type) {
+	case map[string]interface{}:
+		for k, c := range v {
+			switch {
+			case sel.kind == selectName && sel.name == k,
+				sel.kind == selectWildcard,
+				sel.kind == selectFilter && sel.filter.match(c, root):
+				v[k] = RedactMask
+			}
+		}
+	case []interface{}:
+		indexes := make([]interface{}, len(v))
+		for i := range v {
+			indexes[i] = i
+		}
+		var selected []interface{}
+		switch sel.kind {
+		case selectName, selectIndex:
+			selected = sel.apply(indexes, root, nil)
+		case selectWildcard:
+			selected = indexes
+		case selectSlice:
+			selected = sliceJSONArray(indexes, sel.slice)
+		case selectFilter:
+			for i, c := range v {
+				if sel.filter.match(c, root) {
+					selected = append(selected, i)
+				}
+			}
+		}
+		for _, i := range selected {
+			v[i.(int)] = RedactMask
+		}
+	}
+}
+
+// DumpRequest dumps a request like httputil.DumpRequest with its secrets masked, its body is kept for sending,
+// a compressed body is dumped decoded to be redacted
+func (r *Redactor) DumpRequest(req *http.Request, body bool) ([]byte, error) {
+	dup := req.Clone(req.Context())
+	dup.Header = r.Header(req.Header)
+	dup.URL, _ = url.Parse(r.URL(req.URL))
+	dup.Body = nil
+	if body && req.Body != nil && req.Body != http.NoBody {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(b))
+		if redacted := r.Body(req.Header.Get("Content-Type"), decodeRequestBody(req.Header, b)); len(redacted) > 0 {
+			dup.Body = io.NopCloser(bytes.NewReader(redacted))
+		}
+	}
+	return httputil.DumpRequest(dup, body)
+}
+
+// decodeRequestBody returns the decoded content of a body sent with a Content-Encoding
+func decodeRequestBody(header http.Header, body []byte) []byte {
+	if header.Get("Content-Encoding") == "" {
+		return body
+	}
+	resp := &http.Response{Header: http.Header{"Content-Encoding": header.Values("Content-Encoding")}, Body: io.NopCloser(bytes.NewReader(body))}
+	decodeResponse(resp)
+	defer resp.Body.Close()
+	plain, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return body
+	}
+	return plain
+}
+
+// DumpResponse dumps a response like httputil.DumpResponse with its secrets masked, body is the body already read
+func (r *Redactor) DumpResponse(resp *http.Response, body []byte) ([]byte, error) {
+	dup := *resp
+	dup.Header = r.Header(resp.Header)
+	dup.Body = http.NoBody
+	dup.ContentLength = 0
+	dup.TransferEncoding = nil
+	if body != nil {
+		if redacted := r.Body(resp.Header.Get("Content-Type"), body); len(redacted) > 0 {
+			dup.Body = io.NopCloser(bytes.NewReader(redacted))
+			dup.ContentLength = int64(len(redacted))
+		}
+	}
+	return httputil.DumpResponse(&dup, body != nil)
+}
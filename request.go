@@ -2,18 +2,20 @@ package okhttp
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
-	"net"
 	"net/http"
 	"net/http/cookiejar"
-	"net/http/httputil"
+	"net/http/httptrace"
 	"net/url"
+	"strings"
 	"time"
 
-	"github.com/mredencom/okhttp/log"
 	"golang.org/x/net/publicsuffix"
 )
 
@@ -21,20 +23,41 @@ import (
 var DefaultRequestTimeOut = 5 * time.Second
 
 type Request struct {
-	method        string
-	url           *url.URL
-	header        http.Header
-	cookies       []*http.Cookie
-	body          io.Reader
-	timeout       time.Duration
-	proxy         func(*http.Request) (*url.URL, error)
-	allowRedirect bool
-	debug         bool
-	isPrintBody   bool
-	l             *log.Logger
-}
-
-//SetDebug set debug mode
+	ctx             context.Context
+	method          string
+	url             *url.URL
+	header          http.Header
+	cookies         []*http.Cookie
+	body            io.Reader
+	timeout         time.Duration
+	proxy           func(*http.Request) (*url.URL, error)
+	proxyPool       *ProxyPool
+	proxySession    string
+	dialContext     DialContextFunc
+	protocol        Protocol
+	http2Options    *HTTP2Options
+	tlsConfig       *tls.Config
+	errorPolicy     StatusPolicy
+	errorResult     interface{}
+	errs            []error
+	pathTemplate    string
+	nestedStyle     NestedStyle
+	envelope        *Envelope
+	form            url.Values
+	formCharset     string
+	responseCharset string
+	jar             http.CookieJar
+	compressRequest string
+	eventListeners  []EventListenerFactory
+	tracer          *Tracer
+	redactor        *Redactor
+	allowRedirect   bool
+	debug           bool
+	isPrintBody     bool
+	l               Logger
+}
+
+// SetDebug set debug mode
 func (r *Request) SetDebug(d bool) *Request {
 	r.debug = d
 	return r
@@ -47,6 +70,31 @@ func (r *Request) SetPrintBody(d bool) *Request {
 	return r
 }
 
+// SetContext set the context of the request
+func (r *Request) SetContext(ctx context.Context) *Request {
+	r.ctx = ctx
+	return r
+}
+
+// Context returns the context of the request
+func (r *Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// Err returns the errors recorded by the setters, joined by errors.Join
+func (r *Request) Err() error {
+	return errors.Join(r.errs...)
+}
+
+// addError records an error of a setter, it's returned by Do
+func (r *Request) addError(err error) *Request {
+	r.errs = append(r.errs, err)
+	return r
+}
+
 // Method get http method
 func (r *Request) Method() string {
 	return r.method
@@ -62,6 +110,79 @@ func (r *Request) URLString() string {
 	return r.url.String()
 }
 
+// SetQuery set a query parameter, replacing its values
+func (r *Request) SetQuery(key, value string) *Request {
+	q := r.url.Query()
+	q.Set(key, value)
+	r.url.RawQuery = q.Encode()
+	return r
+}
+
+// AddQuery add a value to a query parameter
+func (r *Request) AddQuery(key, value string) *Request {
+	q := r.url.Query()
+	q.Add(key, value)
+	r.url.RawQuery = q.Encode()
+	return r
+}
+
+// SetQueryStruct set the query parameters encoded from a struct with tags like `query:"name,omitempty"`,
+// slices repeat their key and nested structs and maps use keys like a[b]
+func (r *Request) SetQueryStruct(v interface{}) *Request {
+	values, err := valuesEncoder{tag: "query", style: r.nestedStyle}.encode(v)
+	if err != nil {
+		return r.addError(err)
+	}
+	q := r.url.Query()
+	for k, vs := range values {
+		q[k] = vs
+	}
+	r.url.RawQuery = q.Encode()
+	return r
+}
+
+// SetPathTemplate expands an RFC 6570 URI template like /users/{id}/repos{?page,per_page}
+// and appends it to the request url, the query parameters it expands are merged
+func (r *Request) SetPathTemplate(template string, vars map[string]interface{}) *Request {
+	expanded, err := ExpandURITemplate(template, vars)
+	if err != nil {
+		return r.addError(err)
+	}
+	ref, err := url.Parse(expanded)
+	if err != nil {
+		return r.addError(err)
+	}
+	r.pathTemplate = template
+	if ref.IsAbs() {
+		r.url = ref
+		return r
+	}
+
+	if ref.Path != "" {
+		joined, err := url.Parse(strings.TrimSuffix(r.url.EscapedPath(), "/") + "/" + strings.TrimPrefix(ref.EscapedPath(), "/"))
+		if err != nil {
+			return r.addError(err)
+		}
+		r.url.Path, r.url.RawPath = joined.Path, joined.RawPath
+	}
+	if ref.RawQuery != "" {
+		q := r.url.Query()
+		for k, vs := range ref.Query() {
+			q[k] = vs
+		}
+		r.url.RawQuery = q.Encode()
+	}
+	if ref.Fragment != "" {
+		r.url.Fragment = ref.Fragment
+	}
+	return r
+}
+
+// PathTemplate returns the template set by SetPathTemplate
+func (r *Request) PathTemplate() string {
+	return r.pathTemplate
+}
+
 // SetTimeOut set default request time
 func (r *Request) SetTimeOut(d time.Duration) *Request {
 	if d > 0 {
@@ -78,11 +199,21 @@ func (r *Request) SetRedirects(i bool) *Request {
 	return r
 }
 
-// GetHeaders get all request header
+// GetHeaders get all request header, the value of a repeated header is a []string of all its values
 func (r *Request) GetHeaders() H {
 	return doHeader(r.header)
 }
 
+// GetHeaderValues returns all the values of a request header
+func (r *Request) GetHeaderValues(key string) []string {
+	return r.header.Values(key)
+}
+
+// HeaderFields returns the ordered view of the request headers for debugging
+func (r *Request) HeaderFields() HeaderFields {
+	return orderedHeaders(r.header)
+}
+
 // SetHeader set request header
 func (r *Request) SetHeader(key, value string) *Request {
 	r.header.Set(key, value)
@@ -100,6 +231,18 @@ func (r *Request) SetHeaders(headers map[string]string) *Request {
 	return r
 }
 
+// SetHeadersStruct set request headers encoded from a struct with tags like `header:"X-Request-Id,omitempty"`
+func (r *Request) SetHeadersStruct(v interface{}) *Request {
+	headers, err := encodeHeaders(v)
+	if err != nil {
+		return r.addError(err)
+	}
+	for k, vs := range headers {
+		r.header[k] = vs
+	}
+	return r
+}
+
 // AddHeader add request header
 func (r *Request) AddHeader(key, value string) *Request {
 	r.header.Add(key, value)
@@ -122,9 +265,59 @@ func (r *Request) SetReferer(referer string) *Request {
 func (r *Request) SetProxy(proxyURL string) *Request {
 	parse, err := url.Parse(proxyURL)
 	if err != nil {
-		panic("illegal url")
+		return r.addError(fmt.Errorf("illegal proxy url: %w", err))
 	}
 	r.proxy = http.ProxyURL(parse)
+	r.proxyPool = nil
+	return r
+}
+
+// SetProxyPool set a proxy pool to pick the proxy of the request
+func (r *Request) SetProxyPool(pool *ProxyPool) *Request {
+	if pool == nil {
+		return r.addError(errors.New("nil proxy pool"))
+	}
+	r.proxy = pool.Proxy
+	r.proxyPool = pool
+	return r
+}
+
+// SetProxySession pins the request to the proxy of a session when the pool is StickySession
+func (r *Request) SetProxySession(session string) *Request {
+	r.proxySession = session
+	return r
+}
+
+// SetDialContext set the dialer of the request
+func (r *Request) SetDialContext(dial DialContextFunc) *Request {
+	r.dialContext = dial
+	return r
+}
+
+// SetUnixSocket connects the request to a unix socket like unix:///var/run/docker.sock
+func (r *Request) SetUnixSocket(address string) *Request {
+	dial, err := UnixDialer(address)
+	if err != nil {
+		return r.addError(err)
+	}
+	return r.SetDialContext(dial)
+}
+
+// SetProtocol set the http version spoken by the request
+func (r *Request) SetProtocol(p Protocol) *Request {
+	r.protocol = p
+	return r
+}
+
+// SetHTTP2Options set the knobs of HTTP/2 connections
+func (r *Request) SetHTTP2Options(o HTTP2Options) *Request {
+	r.http2Options = &o
+	return r
+}
+
+// SetTLSConfig set the tls config of the request
+func (r *Request) SetTLSConfig(c *tls.Config) *Request {
+	r.tlsConfig = c
 	return r
 }
 
@@ -134,6 +327,12 @@ func (r *Request) SetCookie(cookie *http.Cookie) *Request {
 	return r
 }
 
+// SetCookieJar set the cookie jar keeping the cookies of the request and its response, nil uses a new one
+func (r *Request) SetCookieJar(jar http.CookieJar) *Request {
+	r.jar = jar
+	return r
+}
+
 // SetBody sets request body
 func (r *Request) SetBody(body io.Reader) *Request {
 	r.body = body
@@ -142,19 +341,49 @@ func (r *Request) SetBody(body io.Reader) *Request {
 
 // SetForm sets request form and returns response
 func (r *Request) SetForm(v url.Values) *Request {
+	r.form = v
+	if r.formCharset != "" {
+		encoded, err := encodeFormCharset(v, r.formCharset)
+		if err != nil {
+			return r.addError(err)
+		}
+		v = encoded
+	}
 	r.SetHeader("Content-Type", "application/x-www-form-urlencoded")
-	// todo err
 	return r.SetBody(bytes.NewBuffer([]byte(v.Encode())))
 }
 
+// SetFormStruct sets request form encoded from a struct with tags like `form:"name,omitempty"`
+func (r *Request) SetFormStruct(v interface{}) *Request {
+	values, err := valuesEncoder{tag: "form", style: r.nestedStyle}.encode(v)
+	if err != nil {
+		return r.addError(err)
+	}
+	return r.SetForm(values)
+}
+
+// SetNestedStyle set the style of nested keys encoded by SetFormStruct and SetQueryStruct, default a[b]
+func (r *Request) SetNestedStyle(style NestedStyle) *Request {
+	r.nestedStyle = style
+	return r
+}
+
 // SetJSON sets request JSON and returns response
 func (r *Request) SetJSON(v interface{}) *Request {
+	return r.SetBodyAs("application/json", v)
+}
 
-	r.SetHeader("Content-Type", "application/json")
-	body, err := json.Marshal(v)
+// SetBodyAs encodes v with the codec registered for contentType and sets it as request body
+func (r *Request) SetBodyAs(contentType string, v interface{}) *Request {
+	codec, err := LookupCodec(contentType)
 	if err != nil {
-		panic("json encode err " + err.Error())
+		return r.addError(err)
 	}
+	body, err := codec.Marshal(v)
+	if err != nil {
+		return r.addError(fmt.Errorf("%s encode err: %w", contentType, err))
+	}
+	r.SetHeader("Content-Type", contentType)
 	return r.SetBody(bytes.NewBuffer(body))
 }
 
@@ -167,61 +396,152 @@ func (r *Request) SetBasicAuth(username, password string) *Request {
 
 // Do returns response
 func (r *Request) Do() (*Response, error) {
+	response, err := r.do()
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
 
-	client, err := r.client()
+	resp, err := r.response(response)
 	if err != nil {
 		return nil, err
 	}
+	if r.errorPolicy != nil && r.errorPolicy(resp.status) {
+		return resp, r.httpError(resp)
+	}
+	return resp, nil
+}
+
+// do sends the request and returns the http response with its body unread
+func (r *Request) do() (*http.Response, error) {
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
 
-	request, err := http.NewRequest(r.method, r.url.String(), r.body)
+	client, err := r.client()
 	if err != nil {
 		return nil, err
 	}
-	for k := range r.header {
-		request.Header.Add(k, r.header.Get(k))
+
+	c := r.newCall()
+	ctx := httptrace.WithClientTrace(r.Context(), c.trace())
+	c.l = r.l
+	if r.tracer != nil {
+		var traceID TraceID
+		ctx, traceID = r.tracer.startCall(ctx)
+		// the trace id is the request id of the logs of the call
+		c.l, c.traceID = withTraceID(r.l, traceID.String()), traceID.String()
+	}
+	choice := &proxyChoice{}
+	if r.proxyPool != nil {
+		ctx = context.WithValue(ctx, proxyChoiceKey{}, choice)
+	}
+
+	request, err := r.httpRequest(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	if r.debug {
-		dumpRequest, _ := httputil.DumpRequest(request, r.isPrintBody)
-		r.l.Info(string(dumpRequest))
+		redactor := r.getRedactor()
+		dumpRequest, _ := redactor.DumpRequest(request, r.isPrintBody)
+		c.l.Info(string(dumpRequest), "method", request.Method, "url", redactor.URL(request.URL))
 	}
+	c.listener.CallStart(request)
+	start := time.Now()
 	response, err := client.Do(request)
+	if r.proxyPool != nil {
+		r.proxyPool.Report(choice.url, time.Since(start), proxyError(response, err))
+	}
 	if err != nil {
+		client.CloseIdleConnections()
+		c.fail(err)
 		return nil, err
 	}
-	defer response.Body.Close()
+	c.listener.ResponseHeadersEnd(response)
+	if r.acceptsCompression() {
+		decodeResponse(response)
+	}
+	// every request owns its transport, release its connections with the body
+	response.Body = &clientBody{ReadCloser: response.Body, client: client, call: c}
+	return response, nil
+}
+
+// httpRequest create the http request sent by Do
+func (r *Request) httpRequest(ctx context.Context) (*http.Request, error) {
+	if r.proxySession != "" {
+		ctx = WithProxySession(ctx, r.proxySession)
+	}
+	body := r.body
+	if r.compressRequest != "" && body != nil {
+		compressed, err := compressBody(r.compressRequest, body)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(compressed)
+	}
+	request, err := http.NewRequestWithContext(ctx, r.method, r.url.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	addHeaders(request, r.header)
+	if r.compressRequest != "" && body != nil {
+		request.Header.Set("Content-Encoding", r.compressRequest)
+	}
+	if r.acceptsCompression() {
+		request.Header.Set("Accept-Encoding", AcceptEncoding)
+	}
+	return request, nil
+}
+
+// clientBody closes the idle connections of its client with the body and ends its call
+type clientBody struct {
+	io.ReadCloser
+	client *http.Client
+	call   *call
+}
+
+func (b *clientBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.call.bodyRead(n, err)
+	return n, err
+}
 
-	return r.response(response)
+func (b *clientBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.client.CloseIdleConnections()
+	b.call.end()
+	return err
 }
 
 // client create a request client
 func (r *Request) client() (*http.Client, error) {
 
-	jar, err := cookiejar.New(&cookiejar.Options{
-		PublicSuffixList: publicsuffix.List,
-	})
+	jar := r.jar
+	if jar == nil {
+		var err error
+		jar, err = cookiejar.New(&cookiejar.Options{
+			PublicSuffixList: publicsuffix.List,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	jar.SetCookies(r.url, r.cookies)
 
+	transport, err := r.transport()
 	if err != nil {
 		return nil, err
 	}
-	jar.SetCookies(r.url, r.cookies)
+
+	if r.tracer != nil {
+		transport = &tracingTransport{base: transport, tracer: r.tracer, request: r}
+	}
 
 	client := &http.Client{
-		Transport: &http.Transport{
-			// 设置代理
-			Proxy: r.proxy,
-			DialContext: (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			ForceAttemptHTTP2:     true,
-			MaxIdleConns:          100,
-			IdleConnTimeout:       90 * time.Second,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-		},
-		Jar:     jar,
-		Timeout: r.timeout,
+		Transport: transport,
+		Jar:       jar,
+		Timeout:   r.timeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			if !r.allowRedirect {
 				return http.ErrUseLastResponse
@@ -245,16 +565,35 @@ func (r *Request) response(response *http.Response) (*Response, error) {
 	}
 
 	res := &Response{
-		request: r,
-		status:  response.StatusCode,
-		headers: response.Header,
-		cookies: response.Cookies(),
-		body:    body,
+		request:    r,
+		status:     response.StatusCode,
+		statusText: response.Status,
+		proto:      response.Proto,
+		headers:    response.Header,
+		cookies:    response.Cookies(),
+		body:       body,
+	}
+	if response.Request != nil {
+		res.url = response.Request.URL
+	}
+	l := r.l
+	if b, ok := response.Body.(*clientBody); ok {
+		res.timings = b.call.end()
+		res.traceID = b.call.traceID
+		l = b.call.l
+		if d, ok := b.ReadCloser.(*decodedBody); ok {
+			res.contentEncoding = d.encoding
+			res.compressedSize = d.wire.n
+		}
 	}
 
 	if r.debug {
-		dumpResponse, _ := httputil.DumpResponse(response, r.isPrintBody)
-		r.l.Info(string(dumpResponse))
+		var dumpBody []byte
+		if r.isPrintBody {
+			dumpBody = body
+		}
+		dumpResponse, _ := r.getRedactor().DumpResponse(response, dumpBody)
+		l.Info(string(dumpResponse), "status", response.StatusCode)
 	}
 
 	return res, nil
@@ -1 +1,89 @@
 package okhttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_SetterErrors(t *testing.T) {
+	cases := map[string]func(r *Request) *Request{
+		"SetProxy": func(r *Request) *Request {
+			return r.SetProxy("://bad")
+		},
+		"SetProxyPool": func(r *Request) *Request {
+			return r.SetProxyPool(nil)
+		},
+		"SetUnixSocket": func(r *Request) *Request {
+			return r.SetUnixSocket("tcp://127.0.0.1:80")
+		},
+		"SetJSON": func(r *Request) *Request {
+			return r.SetJSON(make(chan int))
+		},
+		"SetBodyAs codec": func(r *Request) *Request {
+			return r.SetBodyAs("application/x-unknown", "v")
+		},
+		"SetBodyAs encode": func(r *Request) *Request {
+			return r.SetBodyAs("application/x-protobuf", "not a message")
+		},
+		"SetFormStruct not a struct": func(r *Request) *Request {
+			return r.SetFormStruct("a=1")
+		},
+		"SetFormStruct field": func(r *Request) *Request {
+			return r.SetFormStruct(struct {
+				C chan int `form:"c"`
+			}{C: make(chan int)})
+		},
+		"SetQueryStruct not a struct": func(r *Request) *Request {
+			return r.SetQueryStruct(42)
+		},
+		"SetHeadersStruct not a struct": func(r *Request) *Request {
+			return r.SetHeadersStruct("X-Id: 1")
+		},
+		"SetHeadersStruct field": func(r *Request) *Request {
+			return r.SetHeadersStruct(struct {
+				C chan int `header:"X-C"`
+			}{C: make(chan int)})
+		},
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf(`Request with setter error should not be sent`)
+	}))
+	defer ts.Close()
+
+	for name, set := range cases {
+		req, _ := Post(ts.URL)
+		if req.Err() != nil {
+			t.Fatalf(`%s: new request should have no error, %v given`, name, req.Err())
+		}
+		set(req)
+		if req.Err() == nil {
+			t.Errorf(`%s: error should be recorded`, name)
+		}
+		if _, err := req.Do(); err == nil || err.Error() != req.Err().Error() {
+			t.Errorf(`%s: Do should return "%v", "%v" given`, name, req.Err(), err)
+		}
+	}
+}
+
+func Test_SetterErrorsJoined(t *testing.T) {
+	req, _ := Post("http://example.com")
+	req.SetBodyAs("application/x-unknown", "v").SetUnixSocket("")
+	if !errors.Is(req.Err(), NoMatchCodec) {
+		t.Errorf(`Joined error should wrap %v, %v given`, NoMatchCodec, req.Err())
+	}
+	if len(req.Err().(interface{ Unwrap() []error }).Unwrap()) != 2 {
+		t.Errorf(`Both errors should be joined, %v given`, req.Err())
+	}
+
+	client := NewClient().SetUnixSocket("")
+	if client.Err() == nil {
+		t.Fatalf(`Client error should be recorded`)
+	}
+	req, _ = client.Get("http://example.com")
+	if _, err := req.Do(); err == nil {
+		t.Errorf(`Request of client with error should fail`)
+	}
+}
@@ -1,17 +1,43 @@
 package okhttp
 
 import (
+	"bytes"
 	"encoding/json"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
 )
 
 // Response r
 type Response struct {
-	request *Request
-	headers http.Header
-	cookies []*http.Cookie
-	status  int
-	body    []byte
+	request    *Request
+	headers    http.Header
+	cookies    []*http.Cookie
+	status     int
+	statusText string
+	proto      string
+	body       []byte
+	url        *url.URL
+
+	contentEncoding string
+	compressedSize  int64
+	timings         Timings
+	traceID         string
+
+	textOnce sync.Once
+	textBody []byte
+	textErr  error
+	charset  string
+
+	docOnce sync.Once
+	doc     interface{}
+	docErr  error
+
+	htmlOnce sync.Once
+	html     *Document
+	htmlErr  error
 }
 
 // GetCookies returns response cookies slice
@@ -24,21 +50,84 @@ func (r *Response) GetBody() []byte {
 	return r.body
 }
 
-// String returns response body as string
+// String returns response body as string, transcoded to UTF-8 from the charset of the response
 func (r *Response) String() string {
-	return string(r.GetBody())
+	body, _ := r.text()
+	return string(body)
 }
 
-// GetJSON unmarshal JSON response to struct
+// GetJSON unmarshal JSON response to struct, only its data when the request has an envelope
 func (r *Response) GetJSON(v interface{}) error {
-	return json.Unmarshal(r.body, v)
+	codec, err := LookupCodec("application/json")
+	if err != nil {
+		return err
+	}
+	body, err := r.text()
+	if err != nil {
+		return err
+	}
+	if body, err = r.envelopeData(body); err != nil {
+		return err
+	}
+	return codec.Unmarshal(body, v)
+}
+
+// GetForm unmarshal form-encoded response to struct with tags like `form:"name"`, or to *url.Values
+func (r *Response) GetForm(v interface{}) error {
+	codec, err := LookupCodec("application/x-www-form-urlencoded")
+	if err != nil {
+		return err
+	}
+	return codec.Unmarshal(r.body, v)
+}
+
+// Decode unmarshal the response with the codec of its Content-Type, JSON when it has none,
+// only its data when the request has an envelope
+func (r *Response) Decode(v interface{}) error {
+	return r.decode(v, true)
+}
+
+func (r *Response) decode(v interface{}, unwrap bool) error {
+	contentType := r.GetHeader("Content-Type")
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	codec, err := LookupCodec(contentType)
+	if err != nil {
+		return err
+	}
+	body := r.body
+	if mediaType, _, _ := mime.ParseMediaType(contentType); isTextMediaType(mediaType) {
+		if body, err = r.text(); err != nil {
+			return err
+		}
+	}
+	if unwrap {
+		if body, err = r.envelopeData(body); err != nil {
+			return err
+		}
+	}
+	return codec.Unmarshal(body, v)
 }
 
-// GetHeaders return response headers
+// GetHeaders return response headers, the value of a repeated header is a []string of all its values
 func (r *Response) GetHeaders() H {
 	return doHeader(r.headers)
 }
 
+// DecodeHeaders unmarshal response headers to struct with tags like `header:"X-RateLimit-Remaining"`
+func (r *Response) DecodeHeaders(v interface{}) error {
+	return decodeHeaders(r.headers, v)
+}
+
+// URL returns the final URL of the request, after redirects
+func (r *Response) URL() *url.URL {
+	if r.url == nil && r.request != nil {
+		return r.request.url
+	}
+	return r.url
+}
+
 // GetRequest returns initial request
 func (r *Response) GetRequest() *Request {
 	return r.request
@@ -53,3 +142,164 @@ func (r *Response) GetStatus() int {
 func (r *Response) GetHeader(key string) string {
 	return r.headers.Get(key)
 }
+
+// Protocol returns the negotiated protocol like HTTP/1.1 or HTTP/2.0
+func (r *Response) Protocol() string {
+	return r.proto
+}
+
+// GetHeaderValues returns all the values of a response header
+func (r *Response) GetHeaderValues(key string) []string {
+	return r.headers.Values(key)
+}
+
+// Headers returns a copy of the response headers with all their values
+func (r *Response) Headers() http.Header {
+	return r.headers.Clone()
+}
+
+// HeaderFields returns the ordered view of the response headers for debugging
+func (r *Response) HeaderFields() HeaderFields {
+	return orderedHeaders(r.headers)
+}
+
+// document returns the body parsed once as JSON, numbers are json.Number
+func (r *Response) document() (interface{}, error) {
+	r.docOnce.Do(func() {
+		body, err := r.text()
+		if err != nil {
+			r.docErr = err
+			return
+		}
+		d := json.NewDecoder(bytes.NewReader(body))
+		d.UseNumber()
+		r.docErr = d.Decode(&r.doc)
+	})
+	return r.doc, r.docErr
+}
+
+// JSONPath returns the values matched by a JSONPath like $.items[?(@.price < 10)].id
+// or a dotted path like items.0.id, numbers are json.Number
+func (r *Response) JSONPath(path string) ([]interface{}, error) {
+	p, err := CompileJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := r.document()
+	if err != nil {
+		return nil, err
+	}
+	return p.Find(doc), nil
+}
+
+// Get returns the first value matched by path and whether there is one
+func (r *Response) Get(path string) (interface{}, bool) {
+	values, err := r.JSONPath(path)
+	if err != nil || len(values) == 0 {
+		return nil, false
+	}
+	return values[0], true
+}
+
+// Exists reports whether path matches a value, null included
+func (r *Response) Exists(path string) bool {
+	_, ok := r.Get(path)
+	return ok
+}
+
+// GetString returns the value at path as string, objects and arrays as JSON, "" when it does not exist
+func (r *Response) GetString(path string) string {
+	v, _ := r.Get(path)
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case json.Number:
+		return s.String()
+	case bool:
+		return strconv.FormatBool(s)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// GetInt returns the value at path as int64, numeric strings are parsed and floats truncated
+func (r *Response) GetInt(path string) int64 {
+	v, _ := r.Get(path)
+	switch n := v.(type) {
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return i
+		}
+		f, _ := n.Float64()
+		return int64(f)
+	case string:
+		if i, err := strconv.ParseInt(n, 10, 64); err == nil {
+			return i
+		}
+		f, _ := strconv.ParseFloat(n, 64)
+		return int64(f)
+	case bool:
+		if n {
+			return 1
+		}
+	}
+	return 0
+}
+
+// GetFloat returns the value at path as float64, numeric strings are parsed
+func (r *Response) GetFloat(path string) float64 {
+	v, _ := r.Get(path)
+	switch n := v.(type) {
+	case json.Number:
+		f, _ := n.Float64()
+		return f
+	case string:
+		f, _ := strconv.ParseFloat(n, 64)
+		return f
+	case bool:
+		if n {
+			return 1
+		}
+	}
+	return 0
+}
+
+// GetBool returns the value at path as bool, strings like "true" and non zero numbers are true
+func (r *Response) GetBool(path string) bool {
+	v, _ := r.Get(path)
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		t, _ := strconv.ParseBool(b)
+		return t
+	case json.Number:
+		f, _ := b.Float64()
+		return f != 0
+	}
+	return false
+}
+
+// GetArray returns the array at a singular path, or every value matched by a path with wildcards, slices or filters
+func (r *Response) GetArray(path string) []interface{} {
+	p, err := CompileJSONPath(path)
+	if err != nil {
+		return nil
+	}
+	doc, err := r.document()
+	if err != nil {
+		return nil
+	}
+	values := p.Find(doc)
+	if !p.Singular() {
+		return values
+	}
+	if len(values) > 0 {
+		if a, ok := values[0].([]interface{}); ok {
+			return a
+		}
+	}
+	return nil
+}
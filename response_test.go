@@ -1 +1,132 @@
 package okhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_MultiValueHeaders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Values("Accept"); !reflect.DeepEqual(got, []string{"text/html", "application/json"}) {
+			t.Errorf(`Request Accept should keep all values, %v given`, got)
+		}
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Set-Cookie", "b=2")
+		w.Header().Add("Vary", "Accept")
+		w.Header().Add("Vary", "Accept-Encoding")
+		w.Header().Add("Link", `<https://api.example.com/items?page=2>; rel="next"`)
+		w.Header().Add("Link", `<https://api.example.com/items?page=9>; rel="last"`)
+	}))
+	defer ts.Close()
+
+	req, _ := Get(ts.URL)
+	req.AddHeader("Accept", "text/html").AddHeader("Accept", "application/json")
+	if got := req.GetHeaderValues("Accept"); !reflect.DeepEqual(got, []string{"text/html", "application/json"}) {
+		t.Errorf(`GetHeaderValues should keep all values, %v given`, got)
+	}
+	if got := req.GetHeaders()["Accept"]; !reflect.DeepEqual(got, []string{"text/html", "application/json"}) {
+		t.Errorf(`GetHeaders should keep all values, %v given`, got)
+	}
+	resp, err := req.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := resp.GetHeaderValues("Set-Cookie"); !reflect.DeepEqual(got, []string{"a=1", "b=2"}) {
+		t.Errorf(`Set-Cookie should keep all values, %v given`, got)
+	}
+	if len(resp.GetCookies()) != 2 {
+		t.Errorf(`Response should contain 2 cookies, %d given`, len(resp.GetCookies()))
+	}
+	if got := resp.Headers().Values("Vary"); !reflect.DeepEqual(got, []string{"Accept", "Accept-Encoding"}) {
+		t.Errorf(`Vary should keep all values, %v given`, got)
+	}
+	if got := resp.GetHeaders()["Vary"]; !reflect.DeepEqual(got, []string{"Accept", "Accept-Encoding"}) {
+		t.Errorf(`GetHeaders should keep all values, %v given`, got)
+	}
+	if got := resp.GetHeaders()["Set-Cookie"]; !reflect.DeepEqual(got, []string{"a=1", "b=2"}) {
+		t.Errorf(`GetHeaders should keep all Set-Cookie values, %v given`, got)
+	}
+	if got := resp.Headers().Values("Link"); len(got) != 2 || !strings.Contains(got[1], `rel="last"`) {
+		t.Errorf(`Link should keep all values in order, %v given`, got)
+	}
+	if got := resp.GetHeaders()["Content-Length"]; got != "0" {
+		t.Errorf(`Single value header should stay a string, %v given`, got)
+	}
+
+	fields := resp.HeaderFields().String()
+	if !strings.Contains(fields, "Set-Cookie: a=1\r\nSet-Cookie: b=2\r\n") ||
+		strings.Index(fields, "Link:") > strings.Index(fields, "Vary:") {
+		t.Errorf(`Header fields should be sorted by name, %s given`, fields)
+	}
+}
+
+type rateLimitHeaders struct {
+	Limit      int           `header:"X-RateLimit-Limit"`
+	Remaining  *int          `header:"X-RateLimit-Remaining"`
+	RetryAfter time.Duration `header:"Retry-After"`
+	Reset      time.Time     `header:"X-RateLimit-Reset"`
+	Links      []string      `header:"Link"`
+	Ratio      float64       `header:"X-Ratio,omitempty"`
+	Missing    string        `header:"X-Missing,omitempty"`
+}
+
+func Test_DecodeHeaders(t *testing.T) {
+	remaining := 42
+	sent := rateLimitHeaders{
+		Limit:      100,
+		Remaining:  &remaining,
+		RetryAfter: 30 * time.Second,
+		Reset:      time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Links:      []string{"<a>", "<b>"},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for k, vs := range r.Header {
+			if strings.HasPrefix(k, "X-") || k == "Retry-After" || k == "Link" {
+				w.Header()[k] = vs
+			}
+		}
+		w.Header().Add("Link", "<c>, <d>")
+	}))
+	defer ts.Close()
+
+	req, _ := Get(ts.URL)
+	resp, err := req.SetHeadersStruct(sent).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetHeader("X-Missing") != "" || resp.GetHeader("X-Ratio") != "" {
+		t.Errorf(`omitempty headers should not be sent`)
+	}
+	if resp.GetHeader("X-RateLimit-Reset") != "Tue, 02 Jan 2024 03:04:05 GMT" {
+		t.Errorf(`X-RateLimit-Reset should be "%s", "%s" given`, "Tue, 02 Jan 2024 03:04:05 GMT", resp.GetHeader("X-RateLimit-Reset"))
+	}
+
+	var got rateLimitHeaders
+	if err := resp.DecodeHeaders(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Limit != 100 || got.Remaining == nil || *got.Remaining != 42 || got.RetryAfter != 30*time.Second || !got.Reset.Equal(sent.Reset) {
+		t.Errorf(`DecodeHeaders should be %+v, %+v given`, sent, got)
+	}
+	if strings.Join(got.Links, " ") != "<a> <b> <c> <d>" {
+		t.Errorf(`Links should be "%s", "%s" given`, "<a> <b> <c> <d>", strings.Join(got.Links, " "))
+	}
+
+	resp.headers.Set("Retry-After", "1m30s")
+	if err := resp.DecodeHeaders(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.RetryAfter != 90*time.Second {
+		t.Errorf(`RetryAfter should be "%s", "%s" given`, 90*time.Second, got.RetryAfter)
+	}
+
+	resp.headers.Set("X-RateLimit-Limit", "many")
+	if err := resp.DecodeHeaders(&got); err == nil {
+		t.Errorf(`DecodeHeaders of a bad int should be an error`)
+	}
+}
@@ -0,0 +1,551 @@
+package okhttp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// Selector is a compiled CSS selector group like "div.item > a[href], li:nth-child(2n+1)",
+// it supports type, universal, id, class and attribute selectors, the four combinators and the pseudo classes
+// :first-child, :last-child, :only-child, :nth-child, :nth-last-child, :first-of-type, :last-of-type,
+// :nth-of-type, :nth-last-of-type, :only-of-type, :root, :empty, :checked, :disabled, :not, :has and :contains
+type Selector struct {
+	source string
+	group  []cssComplex
+}
+
+// cssComplex is a chain of compound selectors, combinators[i] joins compounds[i] and compounds[i+1]
+type cssComplex struct {
+	compounds   []cssCompound
+	combinators []byte
+}
+
+type cssCompound []cssMatcher
+
+type cssMatcher func(n *html.Node) bool
+
+var selectorCache sync.Map
+
+// CompileSelector compiles a CSS selector group
+func CompileSelector(selector string) (*Selector, error) {
+	if s, ok := selectorCache.Load(selector); ok {
+		return s.(*Selector), nil
+	}
+	p := &cssParser{s: selector}
+	group, err := p.group()
+	if err != nil {
+		return nil, fmt.Errorf("css selector %q: %w", selector, err)
+	}
+	p.skipSpaces()
+	if p.i < len(p.s) {
+		return nil, fmt.Errorf("css selector %q: unexpected %q at %d", selector, p.s[p.i], p.i)
+	}
+	s := &Selector{source: selector, group: group}
+	selectorCache.Store(selector, s)
+	return s, nil
+}
+
+// String returns the source of the selector
+func (s *Selector) String() string {
+	return s.source
+}
+
+// Match reports whether an element matches the selector
+func (s *Selector) Match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	for _, c := range s.group {
+		if c.match(len(c.compounds)-1, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchAll returns the elements below root matching the selector in document order
+func (s *Selector) MatchAll(root *html.Node) []*html.Node {
+	var out []*html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if s.Match(c) {
+				out = append(out, c)
+			}
+			walk(c)
+		}
+	}
+	walk(root)
+	return out
+}
+
+func (c cssComplex) match(i int, n *html.Node) bool {
+	if !c.compounds[i].match(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch c.combinators[i-1] {
+	case '>':
+		p := n.Parent
+		return p != nil && p.Type == html.ElementNode && c.match(i-1, p)
+	case '+':
+		p := prevElement(n)
+		return p != nil && c.match(i-1, p)
+	case '~':
+		for p := prevElement(n); p != nil; p = prevElement(p) {
+			if c.match(i-1, p) {
+				return true
+			}
+		}
+	default:
+		for p := n.Parent; p != nil && p.Type == html.ElementNode; p = p.Parent {
+			if c.match(i-1, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c cssCompound) match(n *html.Node) bool {
+	for _, m := range c {
+		if !m(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func prevElement(n *html.Node) *html.Node {
+	for p := n.PrevSibling; p != nil; p = p.PrevSibling {
+		if p.Type == html.ElementNode {
+			return p
+		}
+	}
+	return nil
+}
+
+func nextElement(n *html.Node) *html.Node {
+	for p := n.NextSibling; p != nil; p = p.NextSibling {
+		if p.Type == html.ElementNode {
+			return p
+		}
+	}
+	return nil
+}
+
+// attr returns the value of an attribute of a node
+func attr(n *html.Node, name string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+type cssParser struct {
+	s string
+	i int
+}
+
+func (p *cssParser) skipSpaces() bool {
+	start := p.i
+	for p.i < len(p.s) && strings.IndexByte(" \t\n\r\f", p.s[p.i]) >= 0 {
+		p.i++
+	}
+	return p.i > start
+}
+
+func (p *cssParser) peek() byte {
+	if p.i < len(p.s) {
+		return p.s[p.i]
+	}
+	return 0
+}
+
+func (p *cssParser) group() ([]cssComplex, error) {
+	var group []cssComplex
+	for {
+		c, err := p.complex()
+		if err != nil {
+			return nil, err
+		}
+		group = append(group, c)
+		p.skipSpaces()
+		if p.peek() != ',' {
+			return group, nil
+		}
+		p.i++
+	}
+}
+
+func (p *cssParser) complex() (cssComplex, error) {
+	c := cssComplex{}
+	p.skipSpaces()
+	for {
+		compound, err := p.compound()
+		if err != nil {
+			return c, err
+		}
+		c.compounds = append(c.compounds, compound)
+
+		spaced := p.skipSpaces()
+		switch ch := p.peek(); {
+		case ch == '>' || ch == '+' || ch == '~':
+			p.i++
+			p.skipSpaces()
+			c.combinators = append(c.combinators, ch)
+		case ch == 0 || ch == ',' || ch == ')':
+			return c, nil
+		case spaced:
+			c.combinators = append(c.combinators, ' ')
+		default:
+			return c, fmt.Errorf("unexpected %q at %d", ch, p.i)
+		}
+	}
+}
+
+func (p *cssParser) compound() (cssCompound, error) {
+	var c cssCompound
+	switch ch := p.peek(); {
+	case ch == '*':
+		p.i++
+		c = append(c, func(n *html.Node) bool { return n.Type == html.ElementNode })
+	case isIdentStart(ch):
+		tag := strings.ToLower(p.ident())
+		c = append(c, func(n *html.Node) bool { return n.Type == html.ElementNode && n.Data == tag })
+	}
+	for {
+		switch p.peek() {
+		case '#':
+			p.i++
+			id := p.ident()
+			if id == "" {
+				return nil, fmt.Errorf("empty id at %d", p.i)
+			}
+			c = append(c, func(n *html.Node) bool {
+				v, _ := attr(n, "id")
+				return v == id
+			})
+		case '.':
+			p.i++
+			class := p.ident()
+			if class == "" {
+				return nil, fmt.Errorf("empty class at %d", p.i)
+			}
+			c = append(c, func(n *html.Node) bool {
+				v, _ := attr(n, "class")
+				for _, f := range strings.Fields(v) {
+					if f == class {
+						return true
+					}
+				}
+				return false
+			})
+		case '[':
+			m, err := p.attribute()
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, m)
+		case ':':
+			m, err := p.pseudo()
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, m)
+		default:
+			if len(c) == 0 {
+				return nil, fmt.Errorf("expected selector at %d", p.i)
+			}
+			return c, nil
+		}
+	}
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '-' || c == '\\' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c >= 0x80
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || '0' <= c && c <= '9'
+}
+
+// ident reads an identifier, a backslash escapes the next character
+func (p *cssParser) ident() string {
+	b := strings.Builder{}
+	for p.i < len(p.s) && isIdentChar(p.s[p.i]) {
+		if p.s[p.i] == '\\' && p.i+1 < len(p.s) {
+			p.i++
+		}
+		b.WriteByte(p.s[p.i])
+		p.i++
+	}
+	return b.String()
+}
+
+func (p *cssParser) attribute() (cssMatcher, error) {
+	p.i++
+	p.skipSpaces()
+	name := strings.ToLower(p.ident())
+	if name == "" {
+		return nil, fmt.Errorf("empty attribute at %d", p.i)
+	}
+	p.skipSpaces()
+	if p.peek() == ']' {
+		p.i++
+		return func(n *html.Node) bool {
+			_, ok := attr(n, name)
+			return ok
+		}, nil
+	}
+
+	op := ""
+	if strings.IndexByte("~|^$*", p.peek()) >= 0 {
+		op = p.s[p.i : p.i+1]
+		p.i++
+	}
+	if p.peek() != '=' {
+		return nil, fmt.Errorf("expected = at %d", p.i)
+	}
+	p.i++
+	p.skipSpaces()
+	var value string
+	if ch := p.peek(); ch == '"' || ch == '\'' {
+		v, err := readQuoted(p.s, &p.i)
+		if err != nil {
+			return nil, err
+		}
+		value = v
+	} else {
+		value = p.ident()
+	}
+	p.skipSpaces()
+	fold := false
+	if ch := p.peek(); ch == 'i' || ch == 'I' {
+		p.i++
+		p.skipSpaces()
+		fold = true
+	}
+	if p.peek() != ']' {
+		return nil, fmt.Errorf("unclosed [ at %d", p.i)
+	}
+	p.i++
+	if fold {
+		value = strings.ToLower(value)
+	}
+
+	return func(n *html.Node) bool {
+		v, ok := attr(n, name)
+		if !ok {
+			return false
+		}
+		if fold {
+			v = strings.ToLower(v)
+		}
+		switch op {
+		case "~":
+			for _, f := range strings.Fields(v) {
+				if f == value {
+					return true
+				}
+			}
+			return false
+		case "|":
+			return v == value || strings.HasPrefix(v, value+"-")
+		case "^":
+			return value != "" && strings.HasPrefix(v, value)
+		case "$":
+			return value != "" && strings.HasSuffix(v, value)
+		case "*":
+			return value != "" && strings.Contains(v, value)
+		}
+		return v == value
+	}, nil
+}
+
+func (p *cssParser) pseudo() (cssMatcher, error) {
+	p.i++
+	if p.peek() == ':' {
+		return nil, fmt.Errorf("pseudo elements are not supported at %d", p.i)
+	}
+	name := strings.ToLower(p.ident())
+	switch name {
+	case "first-child":
+		return nthMatcher(0, 1, false, false), nil
+	case "last-child":
+		return nthMatcher(0, 1, true, false), nil
+	case "only-child":
+		return func(n *html.Node) bool { return prevElement(n) == nil && nextElement(n) == nil }, nil
+	case "first-of-type":
+		return nthMatcher(0, 1, false, true), nil
+	case "last-of-type":
+		return nthMatcher(0, 1, true, true), nil
+	case "only-of-type":
+		first, last := nthMatcher(0, 1, false, true), nthMatcher(0, 1, true, true)
+		return func(n *html.Node) bool { return first(n) && last(n) }, nil
+	case "root":
+		return func(n *html.Node) bool { return n.Parent != nil && n.Parent.Type == html.DocumentNode }, nil
+	case "empty":
+		return func(n *html.Node) bool {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.ElementNode || c.Type == html.TextNode && c.Data != "" {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case "checked":
+		return func(n *html.Node) bool {
+			_, checked := attr(n, "checked")
+			_, selected := attr(n, "selected")
+			return n.Data == "input" && checked || n.Data == "option" && selected
+		}, nil
+	case "disabled":
+		return func(n *html.Node) bool {
+			_, ok := attr(n, "disabled")
+			return ok
+		}, nil
+	}
+
+	if p.peek() != '(' {
+		return nil, fmt.Errorf("unknown pseudo class :%s", name)
+	}
+	p.i++
+	p.skipSpaces()
+	var m cssMatcher
+	switch name {
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		start := p.i
+		for p.i < len(p.s) && p.s[p.i] != ')' {
+			p.i++
+		}
+		a, b, err := parseNth(p.s[start:p.i])
+		if err != nil {
+			return nil, err
+		}
+		m = nthMatcher(a, b, strings.Contains(name, "last"), strings.HasSuffix(name, "of-type"))
+	case "not", "has":
+		group, err := p.group()
+		if err != nil {
+			return nil, err
+		}
+		inner := &Selector{group: group}
+		if name == "not" {
+			m = func(n *html.Node) bool { return !inner.Match(n) }
+		} else {
+			m = func(n *html.Node) bool { return len(inner.MatchAll(n)) > 0 }
+		}
+	case "contains":
+		var text string
+		if ch := p.peek(); ch == '"' || ch == '\'' {
+			v, err := readQuoted(p.s, &p.i)
+			if err != nil {
+				return nil, err
+			}
+			text = v
+		} else {
+			text = p.ident()
+		}
+		m = func(n *html.Node) bool { return strings.Contains(nodeText(n), text) }
+	default:
+		return nil, fmt.Errorf("unknown pseudo class :%s()", name)
+	}
+	p.skipSpaces()
+	if p.peek() != ')' {
+		return nil, fmt.Errorf("unclosed :%s( at %d", name, p.i)
+	}
+	p.i++
+	return m, nil
+}
+
+// parseNth parses an+b, odd or even
+func parseNth(s string) (int, int, error) {
+	s = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), " ", ""))
+	switch s {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	}
+	i := strings.IndexByte(s, 'n')
+	if i < 0 {
+		b, err := strconv.Atoi(s)
+		return 0, b, err
+	}
+	a := 1
+	switch s[:i] {
+	case "", "+":
+	case "-":
+		a = -1
+	default:
+		v, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return 0, 0, fmt.Errorf("bad nth %q", s)
+		}
+		a = v
+	}
+	b := 0
+	if rest := s[i+1:]; rest != "" {
+		v, err := strconv.Atoi(rest)
+		if err != nil {
+			return 0, 0, fmt.Errorf("bad nth %q", s)
+		}
+		b = v
+	}
+	return a, b, nil
+}
+
+// nthMatcher matches the elements at a position an+b counted from 1, from the end when last
+func nthMatcher(a, b int, last, ofType bool) cssMatcher {
+	return func(n *html.Node) bool {
+		if n.Parent == nil {
+			return false
+		}
+		pos := 1
+		sibling := prevElement
+		if last {
+			sibling = nextElement
+		}
+		for s := sibling(n); s != nil; s = sibling(s) {
+			if !ofType || s.Data == n.Data {
+				pos++
+			}
+		}
+		if a == 0 {
+			return pos == b
+		}
+		k := (pos - b) / a
+		return k >= 0 && (pos-b)%a == 0
+	}
+}
+
+// nodeText returns the text of a node and its descendants
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	b := strings.Builder{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch c.Type {
+			case html.TextNode:
+				b.WriteString(c.Data)
+			case html.ElementNode:
+				if c.Data != "script" && c.Data != "style" {
+					walk(c)
+				}
+			}
+		}
+	}
+	walk(n)
+	return b.String()
+}
@@ -0,0 +1,327 @@
+package okhttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultEventSourceRetry is the reconnection delay of an EventSource before the server sends a retry field
+var DefaultEventSourceRetry = 3 * time.Second
+
+// Event is a server-sent event
+type Event struct {
+	// ID is the last event id when the event was dispatched
+	ID string
+	// Event is the event type, message when the server sent none
+	Event string
+	Data  string
+}
+
+// EventSource reads a text/event-stream and reconnects when the connection drops
+type EventSource struct {
+	request *Request
+	body    []byte
+	events  chan Event
+	onEvent func(Event)
+	onError func(error)
+
+	mu          sync.Mutex
+	retry       time.Duration
+	lastEventID string
+	err         error
+	started     bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewEventSource created an event source reading the stream of r
+func NewEventSource(r *Request) *EventSource {
+	ctx, cancel := context.WithCancel(r.Context())
+	return &EventSource{
+		request: r,
+		events:  make(chan Event),
+		retry:   DefaultEventSourceRetry,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+}
+
+// OnEvent delivers the events to fn instead of the Events channel
+func (es *EventSource) OnEvent(fn func(Event)) *EventSource {
+	es.onEvent = fn
+	return es
+}
+
+// OnError is called for every connection error, including the ones followed by a reconnection
+func (es *EventSource) OnError(fn func(error)) *EventSource {
+	es.onError = fn
+	return es
+}
+
+// SetRetry set the reconnection delay used until the server sends a retry field
+func (es *EventSource) SetRetry(d time.Duration) *EventSource {
+	es.mu.Lock()
+	es.retry = d
+	es.mu.Unlock()
+	return es
+}
+
+// SetLastEventID set the Last-Event-ID sent by the first connection
+func (es *EventSource) SetLastEventID(id string) *EventSource {
+	es.mu.Lock()
+	es.lastEventID = id
+	es.mu.Unlock()
+	return es
+}
+
+// LastEventID returns the id of the last event received
+func (es *EventSource) LastEventID() string {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	return es.lastEventID
+}
+
+// Events returns the channel of events, it's closed when the event source stops
+func (es *EventSource) Events() <-chan Event {
+	return es.events
+}
+
+// Done is closed when the event source stops
+func (es *EventSource) Done() <-chan struct{} {
+	return es.done
+}
+
+// Err returns the error which stopped the event source
+func (es *EventSource) Err() error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	return es.err
+}
+
+// Start connects in background, it's a no-op once started
+func (es *EventSource) Start() *EventSource {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if es.started {
+		return es
+	}
+	es.started = true
+	go es.run()
+	return es
+}
+
+// Close stops the event source
+func (es *EventSource) Close() {
+	es.cancel()
+	es.mu.Lock()
+	started := es.started
+	es.mu.Unlock()
+	if started {
+		<-es.done
+	}
+}
+
+func (es *EventSource) run() {
+	defer close(es.done)
+	defer close(es.events)
+
+	if es.request.body != nil {
+		// the body is sent again by every reconnection
+		body, err := ioutil.ReadAll(es.request.body)
+		if err != nil {
+			es.stop(err)
+			return
+		}
+		es.body = body
+	}
+
+	for {
+		retryable, err := es.connect()
+		if es.ctx.Err() != nil {
+			es.stop(nil)
+			return
+		}
+		if err != nil && es.onError != nil {
+			es.onError(err)
+		}
+		if !retryable {
+			es.stop(err)
+			return
+		}
+
+		es.mu.Lock()
+		retry := es.retry
+		es.mu.Unlock()
+		select {
+		case <-es.ctx.Done():
+			es.stop(nil)
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+func (es *EventSource) stop(err error) {
+	es.mu.Lock()
+	es.err = err
+	es.mu.Unlock()
+}
+
+// connect reads one connection, it reports whether the event source should reconnect
+func (es *EventSource) connect() (bool, error) {
+	// the stream is endless, the request time out would cut it
+	r := *es.request
+	r.timeout = 0
+	r.ctx = es.ctx
+	r.header = es.request.header.Clone()
+	r.header.Set("Accept", "text/event-stream")
+	r.header.Set("Cache-Control", "no-cache")
+	if id := es.LastEventID(); id != "" {
+		r.header.Set("Last-Event-ID", id)
+	}
+	if es.body != nil {
+		r.body = bytes.NewReader(es.body)
+	}
+
+	// the errors of the setters can't be fixed by reconnecting
+	if err := r.Err(); err != nil {
+		return false, err
+	}
+	response, err := r.do()
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("eventsource: unexpected status %d", response.StatusCode)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		return false, fmt.Errorf("eventsource: unexpected content type %q", response.Header.Get("Content-Type"))
+	}
+
+	err = es.read(response.Body)
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	return true, err
+}
+
+// read parses the stream as described by the WHATWG HTML spec, section 9.2
+func (es *EventSource) read(body io.Reader) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 4096), 1<<20)
+	scanner.Split(scanEventLines)
+
+	var (
+		data      strings.Builder
+		eventType string
+		first     = true
+		// id is the last event ID buffer, it becomes the last event ID when an event is dispatched
+		id = es.LastEventID()
+	)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			first = false
+		}
+
+		if line == "" {
+			es.mu.Lock()
+			es.lastEventID = id
+			es.mu.Unlock()
+			if data.Len() == 0 {
+				eventType = ""
+				continue
+			}
+			event := Event{ID: id, Event: eventType, Data: strings.TrimSuffix(data.String(), "\n")}
+			if event.Event == "" {
+				event.Event = "message"
+			}
+			data.Reset()
+			eventType = ""
+			if !es.dispatch(event) {
+				return es.ctx.Err()
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				id = value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 && strings.Trim(value, "0123456789") == "" {
+				es.SetRetry(time.Duration(ms) * time.Millisecond)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+func (es *EventSource) dispatch(event Event) bool {
+	if es.onEvent != nil {
+		es.onEvent(event)
+		return es.ctx.Err() == nil
+	}
+	select {
+	case es.events <- event:
+		return true
+	case <-es.ctx.Done():
+		return false
+	}
+}
+
+// scanEventLines splits lines ended by CRLF, LF or CR
+func scanEventLines(data []byte, atEOF bool) (int, []byte, error) {
+	for i, b := range data {
+		switch b {
+		case '\n':
+			return i + 1, data[:i], nil
+		case '\r':
+			if i+1 < len(data) {
+				if data[i+1] == '\n' {
+					return i + 2, data[:i], nil
+				}
+				return i + 1, data[:i], nil
+			}
+			if atEOF {
+				return i + 1, data[:i], nil
+			}
+			// wait for the next byte, it may be the LF of a CRLF
+			return 0, nil, nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		// an unterminated line is dropped like an incomplete event
+		return len(data), nil, nil
+	}
+	return 0, nil, nil
+}
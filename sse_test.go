@@ -0,0 +1,117 @@
+package okhttp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_EventSourceParse(t *testing.T) {
+	stream := "\ufeff: comment\r\nevent: greet\r\ndata: hello\r\ndata:  world\r\nid: 1\r\n\r\n" +
+		"data: plain\rid\r\r" +
+		"data\n\n" +
+		"retry: 10\nid: bad\x00id\nevent: skipped\n\n" +
+		"data: incomplete"
+
+	es := NewEventSource(&Request{})
+	var got []Event
+	es.OnEvent(func(e Event) { got = append(got, e) })
+	if err := es.read(strings.NewReader(stream)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Event{
+		{ID: "1", Event: "greet", Data: "hello\n world"},
+		{ID: "", Event: "message", Data: "plain"},
+		{ID: "", Event: "message", Data: ""},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf(`Events should be %v, %v given`, want, got)
+	}
+	if es.retry != 10*time.Millisecond {
+		t.Errorf(`Retry should be %s, %s given`, 10*time.Millisecond, es.retry)
+	}
+}
+
+func Test_EventSourceReconnect(t *testing.T) {
+	var connections int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&connections, 1)
+		if r.Header.Get("Accept") != "text/event-stream" {
+			t.Errorf(`Accept should be "%s", "%s" given`, "text/event-stream", r.Header.Get("Accept"))
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch n {
+		case 1:
+			fmt.Fprint(w, "retry: 10\nid: 7\ndata: first\n\n")
+		case 2:
+			if id := r.Header.Get("Last-Event-ID"); id != "7" {
+				t.Errorf(`Last-Event-ID should be "%s", "%s" given`, "7", id)
+			}
+			fmt.Fprint(w, "id: 8\ndata: second\n\n")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	req, _ := Get(ts.URL)
+	var errs int32
+	es := NewEventSource(req).SetRetry(time.Hour).OnError(func(error) { atomic.AddInt32(&errs, 1) }).Start()
+	defer es.Close()
+
+	var data []string
+	for e := range es.Events() {
+		data = append(data, e.Data)
+	}
+	if strings.Join(data, ",") != "first,second" {
+		t.Errorf(`Events should be "%s", "%s" given`, "first,second", strings.Join(data, ","))
+	}
+	if es.LastEventID() != "8" {
+		t.Errorf(`Last event id should be "%s", "%s" given`, "8", es.LastEventID())
+	}
+	if es.Err() == nil {
+		t.Errorf(`204 should stop the event source with an error`)
+	}
+	if atomic.LoadInt32(&errs) != 3 {
+		t.Errorf(`OnError should be called %d times, %d given`, 3, errs)
+	}
+}
+
+func Test_EventSourceLastEventID(t *testing.T) {
+	es := NewEventSource(&Request{})
+	es.OnEvent(func(Event) {})
+	if err := es.read(strings.NewReader("id: 1\ndata: one\n\nid: 2\ndata: incomplete")); err != nil {
+		t.Fatal(err)
+	}
+	if es.LastEventID() != "1" {
+		t.Errorf(`Last event id of an undispatched event should not be kept, "%s" given`, es.LastEventID())
+	}
+	if err := es.read(strings.NewReader("id: 3\n\n")); err != nil {
+		t.Fatal(err)
+	}
+	if es.LastEventID() != "3" {
+		t.Errorf(`Last event id should be "%s", "%s" given`, "3", es.LastEventID())
+	}
+}
+
+func Test_EventSourceRequestError(t *testing.T) {
+	var connections int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&connections, 1)
+	}))
+	defer ts.Close()
+
+	req, _ := Get(ts.URL)
+	es := NewEventSource(req.SetProxy("%zz")).SetRetry(time.Millisecond).Start()
+	defer es.Close()
+	for range es.Events() {
+	}
+	if es.Err() == nil || atomic.LoadInt32(&connections) != 0 {
+		t.Errorf(`a request error should stop the event source without connecting, %v given`, es.Err())
+	}
+}
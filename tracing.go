@@ -0,0 +1,427 @@
+package okhttp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Propagation selects the headers carrying the span context to the server, they can be combined like
+// PropagationW3C | PropagationB3
+type Propagation int
+
+const (
+	// PropagationW3C injects the traceparent and tracestate headers of W3C Trace Context
+	PropagationW3C Propagation = 1 << iota
+	// PropagationB3 injects the X-B3-* headers of Zipkin
+	PropagationB3
+	// PropagationB3Single injects the single b3 header of Zipkin
+	PropagationB3Single
+)
+
+// TraceID is the 16 bytes id of a trace
+type TraceID [16]byte
+
+// SpanID is the 8 bytes id of a span
+type SpanID [8]byte
+
+// String returns the id as 32 lower case hex digits
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid reports whether the id is not all zero
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+// String returns the id as 16 lower case hex digits
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid reports whether the id is not all zero
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// SpanContext identifies a span across processes
+type SpanContext struct {
+	TraceID TraceID
+	// SpanID is the span of the caller, it's zero for the root of a trace started by the client
+	SpanID  SpanID
+	Sampled bool
+	// TraceState is the vendor data of the tracestate header, sent as is
+	TraceState string
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a context whose calls are children of sc
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context of ctx
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok && sc.TraceID.IsValid()
+}
+
+// ExtractSpanContext reads the span context of a traceparent or b3 header, to continue the trace of a server
+func ExtractSpanContext(h http.Header) (SpanContext, bool) {
+	if sc, ok := parseTraceparent(h.Get("traceparent")); ok {
+		sc.TraceState = h.Get("tracestate")
+		return sc, true
+	}
+	if b3 := h.Get("b3"); b3 != "" {
+		parts := strings.Split(b3, "-")
+		if len(parts) < 2 {
+			return SpanContext{}, false
+		}
+		sampled := "1"
+		if len(parts) > 2 {
+			sampled = parts[2]
+		}
+		return parseB3(parts[0], parts[1], sampled)
+	}
+	if h.Get("X-B3-TraceId") != "" {
+		sampled := h.Get("X-B3-Sampled")
+		if sampled == "" {
+			sampled = "1"
+		}
+		if h.Get("X-B3-Flags") == "1" {
+			sampled = "d"
+		}
+		return parseB3(h.Get("X-B3-TraceId"), h.Get("X-B3-SpanId"), sampled)
+	}
+	return SpanContext{}, false
+}
+
+func parseTraceparent(s string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+	sc := SpanContext{}
+	if !decodeID(sc.TraceID[:], parts[1]) || !decodeID(sc.SpanID[:], parts[2]) || !sc.TraceID.IsValid() || !sc.SpanID.IsValid() {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, true
+}
+
+func parseB3(traceID, spanID, sampled string) (SpanContext, bool) {
+	sc := SpanContext{Sampled: sampled == "1" || sampled == "d" || sampled == "true"}
+	// a 64 bits trace id is left padded
+	if len(traceID) == 16 {
+		traceID = strings.Repeat("0", 16) + traceID
+	}
+	if !decodeID(sc.TraceID[:], traceID) || !decodeID(sc.SpanID[:], spanID) || !sc.TraceID.IsValid() {
+		return SpanContext{}, false
+	}
+	return sc, true
+}
+
+func decodeID(dst []byte, s string) bool {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+// Span is the client span of one round trip, every attempt and redirect of a call has its own
+type Span struct {
+	Name     string
+	TraceID  TraceID
+	SpanID   SpanID
+	ParentID SpanID
+	Sampled  bool
+	Start    time.Time
+	End      time.Time
+	// Attributes follow the OpenTelemetry semantic conventions of HTTP clients
+	Attributes map[string]interface{}
+	// Err is the error of the round trip or of the read of its body
+	Err error
+}
+
+// SpanExporter receives the ended spans which are sampled
+type SpanExporter interface {
+	ExportSpan(span Span)
+}
+
+// SpanExporterFunc is a function exporting spans
+type SpanExporterFunc func(span Span)
+
+// ExportSpan calls f
+func (f SpanExporterFunc) ExportSpan(span Span) {
+	f(span)
+}
+
+// InMemoryExporter keeps the exported spans in memory, it's made for tests
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []Span
+}
+
+// NewInMemoryExporter created an in memory exporter
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+// ExportSpan keeps the span
+func (e *InMemoryExporter) ExportSpan(span Span) {
+	e.mu.Lock()
+	e.spans = append(e.spans, span)
+	e.mu.Unlock()
+}
+
+// Spans returns the spans exported so far in the order they ended
+func (e *InMemoryExporter) Spans() []Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Span(nil), e.spans...)
+}
+
+// Reset forgets the exported spans
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	e.spans = nil
+	e.mu.Unlock()
+}
+
+// Tracer creates the client spans of the calls and propagates their context to the servers
+type Tracer struct {
+	exporter    SpanExporter
+	propagation Propagation
+	sampled     bool
+}
+
+// NewTracer created a tracer exporting to exporter, it injects the W3C headers and samples new traces
+func NewTracer(exporter SpanExporter) *Tracer {
+	return &Tracer{exporter: exporter, propagation: PropagationW3C, sampled: true}
+}
+
+// SetPropagation set the headers injected into every round trip
+func (t *Tracer) SetPropagation(p Propagation) *Tracer {
+	t.propagation = p
+	return t
+}
+
+// SetSampled set whether the traces started by the client are sampled, a call continuing a trace keeps its decision
+func (t *Tracer) SetSampled(sampled bool) *Tracer {
+	t.sampled = sampled
+	return t
+}
+
+// SetTracer traces every call of the client
+func (c *Client) SetTracer(t *Tracer) *Client {
+	c.tracer = t
+	return c
+}
+
+// SetTracer traces the calls of the request
+func (r *Request) SetTracer(t *Tracer) *Request {
+	r.tracer = t
+	return r
+}
+
+// TraceID returns the trace id of the call, "" when it was not traced
+func (r *Response) TraceID() string {
+	return r.traceID
+}
+
+// startCall returns a context carrying the span context of the call,
+// a call without one starts a new trace shared by all its round trips
+func (t *Tracer) startCall(ctx context.Context) (context.Context, TraceID) {
+	if sc, ok := SpanContextFromContext(ctx); ok {
+		return ctx, sc.TraceID
+	}
+	sc := SpanContext{Sampled: t.sampled}
+	rand.Read(sc.TraceID[:])
+	return ContextWithSpanContext(ctx, sc), sc.TraceID
+}
+
+// inject sets the propagation headers of a span
+func (t *Tracer) inject(h http.Header, span *Span, traceState string) {
+	sampled := "0"
+	if span.Sampled {
+		sampled = "1"
+	}
+	if t.propagation&PropagationW3C != 0 {
+		h.Set("traceparent", "00-"+span.TraceID.String()+"-"+span.SpanID.String()+"-0"+sampled)
+		if traceState != "" {
+			h.Set("tracestate", traceState)
+		} else {
+			h.Del("tracestate")
+		}
+	}
+	if t.propagation&PropagationB3 != 0 {
+		h.Set("X-B3-TraceId", span.TraceID.String())
+		h.Set("X-B3-SpanId", span.SpanID.String())
+		if span.ParentID.IsValid() {
+			h.Set("X-B3-ParentSpanId", span.ParentID.String())
+		} else {
+			h.Del("X-B3-ParentSpanId")
+		}
+		h.Set("X-B3-Sampled", sampled)
+	}
+	if t.propagation&PropagationB3Single != 0 {
+		b3 := span.TraceID.String() + "-" + span.SpanID.String() + "-" + sampled
+		if span.ParentID.IsValid() {
+			b3 += "-" + span.ParentID.String()
+		}
+		h.Set("b3", b3)
+	}
+}
+
+// tracingTransport creates a span for every round trip
+type tracingTransport struct {
+	base    http.RoundTripper
+	tracer  *Tracer
+	request *Request
+
+	mu     sync.Mutex
+	resend int
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	parent, _ := SpanContextFromContext(req.Context())
+	span := &Span{
+		Name:     req.Method,
+		TraceID:  parent.TraceID,
+		ParentID: parent.SpanID,
+		Sampled:  parent.Sampled,
+		Start:    time.Now(),
+	}
+	if !span.TraceID.IsValid() {
+		rand.Read(span.TraceID[:])
+		span.Sampled = t.tracer.sampled
+	}
+	rand.Read(span.SpanID[:])
+	if t.request.pathTemplate != "" {
+		span.Name += " " + t.request.pathTemplate
+	}
+
+	t.mu.Lock()
+	resend := t.resend
+	t.resend++
+	t.mu.Unlock()
+
+	span.Attributes = map[string]interface{}{
+		"http.request.method": req.Method,
+		"url.full":            t.request.getRedactor().URL(req.URL),
+		"server.address":      req.URL.Hostname(),
+	}
+	if port := req.URL.Port(); port != "" {
+		span.Attributes["server.port"], _ = strconv.Atoi(port)
+	} else if req.URL.Scheme == "https" {
+		span.Attributes["server.port"] = 443
+	} else {
+		span.Attributes["server.port"] = 80
+	}
+	if t.request.pathTemplate != "" {
+		span.Attributes["url.template"] = t.request.pathTemplate
+	}
+	if resend > 0 {
+		span.Attributes["http.request.resend_count"] = resend
+	}
+
+	// the request of a round trip must not be modified
+	req = req.Clone(req.Context())
+	t.tracer.inject(req.Header, span, parent.TraceState)
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.Err = err
+		span.Attributes["error.type"] = errorType(err)
+		t.end(span)
+		return nil, err
+	}
+	span.Attributes["http.response.status_code"] = resp.StatusCode
+	span.Attributes["network.protocol.version"] = strings.TrimPrefix(resp.Proto, "HTTP/")
+	if resp.StatusCode >= 400 {
+		span.Attributes["error.type"] = strconv.Itoa(resp.StatusCode)
+	}
+	// the body of a switched protocol is the connection, it's kept writable and outlives the call
+	if resp.Body == nil || resp.Body == http.NoBody || resp.StatusCode == http.StatusSwitchingProtocols {
+		t.end(span)
+		return resp, nil
+	}
+	resp.Body = &spanBody{ReadCloser: resp.Body, end: func(err error) {
+		if err != nil && span.Err == nil {
+			span.Err = err
+			span.Attributes["error.type"] = errorType(err)
+		}
+		t.end(span)
+	}}
+	return resp, nil
+}
+
+func (t *tracingTransport) end(span *Span) {
+	span.End = time.Now()
+	if span.Sampled && t.tracer.exporter != nil {
+		t.tracer.exporter.ExportSpan(*span)
+	}
+}
+
+func (t *tracingTransport) CloseIdleConnections() {
+	if c, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// errorType returns the error.type attribute of an error
+func errorType(err error) string {
+	var ne net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &ne) && ne.Timeout():
+		return "timeout"
+	case errors.As(err, &ne):
+		return "network"
+	}
+	return "_OTHER"
+}
+
+// spanBody ends its span at the end of the body or when it's closed
+type spanBody struct {
+	io.ReadCloser
+	once sync.Once
+	end  func(err error)
+}
+
+func (b *spanBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		e := err
+		if e == io.EOF {
+			e = nil
+		}
+		b.once.Do(func() { b.end(e) })
+	}
+	return n, err
+}
+
+func (b *spanBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.end(nil) })
+	return err
+}
@@ -0,0 +1,79 @@
+package okhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Tracing(t *testing.T) {
+	var headers []http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Clone())
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/users/1", http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	exporter := NewInMemoryExporter()
+	client := NewClient().SetTracer(NewTracer(exporter).SetPropagation(PropagationW3C | PropagationB3))
+	req, _ := client.Get(ts.URL + "/redirect")
+	resp, err := req.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf(`a span should be exported for every round trip, %d given`, len(spans))
+	}
+	for i, span := range spans {
+		if span.TraceID.String() != resp.TraceID() {
+			t.Errorf(`TraceID should be "%s", "%s" given`, resp.TraceID(), span.TraceID)
+		}
+		if span.ParentID.IsValid() {
+			t.Errorf(`a span of a new trace should have no parent, "%s" given`, span.ParentID)
+		}
+		sc, ok := ExtractSpanContext(headers[i])
+		if !ok || sc.TraceID != span.TraceID || sc.SpanID != span.SpanID || !sc.Sampled {
+			t.Errorf(`traceparent should carry the span, "%s" given`, headers[i].Get("traceparent"))
+		}
+		if headers[i].Get("X-B3-SpanId") != span.SpanID.String() {
+			t.Errorf(`X-B3-SpanId should be "%s", "%s" given`, span.SpanID, headers[i].Get("X-B3-SpanId"))
+		}
+	}
+	if spans[0].Attributes["http.response.status_code"] != http.StatusFound || spans[1].Attributes["http.request.resend_count"] != 1 {
+		t.Errorf(`the redirect should be a second span, %v given`, spans[1].Attributes)
+	}
+	if spans[1].Attributes["http.request.method"] != "GET" || spans[1].Attributes["url.full"] != ts.URL+"/users/1" {
+		t.Errorf(`span attributes should describe the request, %v given`, spans[1].Attributes)
+	}
+
+	exporter.Reset()
+	headers = nil
+	parent, _ := ExtractSpanContext(http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, "Tracestate": {"congo=t61rcWkgMzE"}})
+	req, _ = client.Get(ts.URL)
+	req.SetContext(ContextWithSpanContext(context.Background(), parent)).SetTracer(NewTracer(exporter).SetPropagation(PropagationB3Single))
+	if _, err := req.Do(); err != nil {
+		t.Fatal(err)
+	}
+	spans = exporter.Spans()
+	if len(spans) != 1 || spans[0].TraceID != parent.TraceID || spans[0].ParentID != parent.SpanID {
+		t.Fatalf(`the span should be a child of the context, %+v given`, spans)
+	}
+	want := "4bf92f3577b34da6a3ce929d0e0e4736-" + spans[0].SpanID.String() + "-1-00f067aa0ba902b7"
+	if headers[0].Get("b3") != want || headers[0].Get("traceparent") != "" {
+		t.Errorf(`b3 should be "%s", "%s" given`, want, headers[0].Get("b3"))
+	}
+
+	exporter.Reset()
+	req, _ = client.Get(ts.URL)
+	req.SetTracer(NewTracer(exporter).SetSampled(false))
+	if _, err := req.Do(); err != nil || len(exporter.Spans()) != 0 {
+		t.Errorf(`a trace which is not sampled should not be exported`)
+	}
+}
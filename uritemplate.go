@@ -0,0 +1,229 @@
+package okhttp
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// uriTemplateOperator is a row of the expansion table of RFC 6570 appendix A
+type uriTemplateOperator struct {
+	first    string
+	sep      string
+	named    bool
+	ifEmpty  string
+	reserved bool
+}
+
+var uriTemplateOperators = map[byte]uriTemplateOperator{
+	0:   {"", ",", false, "", false},
+	'+': {"", ",", false, "", true},
+	'.': {".", ".", false, "", false},
+	'/': {"/", "/", false, "", false},
+	';': {";", ";", true, "", false},
+	'?': {"?", "&", true, "=", false},
+	'&': {"&", "&", true, "=", false},
+	'#': {"#", ",", false, "", true},
+}
+
+// ExpandURITemplate expands an RFC 6570 URI template like /users/{id}/repos{?page,per_page},
+// values are scalars, slices or maps, a missing or nil value is undefined
+func ExpandURITemplate(template string, vars map[string]interface{}) (string, error) {
+	b := strings.Builder{}
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			if strings.IndexByte(template, '}') >= 0 {
+				return "", fmt.Errorf("uri template: unexpected }")
+			}
+			b.WriteString(encodeTemplateLiteral(template))
+			return b.String(), nil
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("uri template: unclosed expression %q", template[start:])
+		}
+		b.WriteString(encodeTemplateLiteral(template[:start]))
+		if err := expandTemplateExpression(&b, template[start+1:start+end], vars); err != nil {
+			return "", err
+		}
+		template = template[start+end+1:]
+	}
+}
+
+func expandTemplateExpression(b *strings.Builder, expr string, vars map[string]interface{}) error {
+	if expr == "" {
+		return fmt.Errorf("uri template: empty expression")
+	}
+	var opKey byte
+	if strings.IndexByte("+#./;?&", expr[0]) >= 0 {
+		opKey, expr = expr[0], expr[1:]
+	} else if strings.IndexByte("=,!@|", expr[0]) >= 0 {
+		return fmt.Errorf("uri template: reserved operator %q", expr[0])
+	}
+	op := uriTemplateOperators[opKey]
+
+	first := true
+	for _, spec := range strings.Split(expr, ",") {
+		name, explode, prefix := spec, false, -1
+		if strings.HasSuffix(name, "*") {
+			name, explode = name[:len(name)-1], true
+		} else if i := strings.IndexByte(name, ':'); i >= 0 {
+			n, err := strconv.Atoi(name[i+1:])
+			if err != nil || n <= 0 || n >= 10000 {
+				return fmt.Errorf("uri template: bad prefix %q", spec)
+			}
+			name, prefix = name[:i], n
+		}
+		if name == "" {
+			return fmt.Errorf("uri template: empty variable in %q", expr)
+		}
+
+		expanded, ok := expandTemplateVar(op, name, vars[name], explode, prefix)
+		if !ok {
+			continue
+		}
+		if first {
+			b.WriteString(op.first)
+			first = false
+		} else {
+			b.WriteString(op.sep)
+		}
+		b.WriteString(expanded)
+	}
+	return nil
+}
+
+// expandTemplateVar expands one varspec, it reports false for an undefined value
+func expandTemplateVar(op uriTemplateOperator, name string, value interface{}, explode bool, prefix int) (string, bool) {
+	if value == nil {
+		return "", false
+	}
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	}
+	encode := func(s string) string { return encodeTemplateValue(s, op.reserved) }
+	named := func(s string) string {
+		if s == "" {
+			return name + op.ifEmpty
+		}
+		return name + "=" + s
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		if v.Len() == 0 {
+			return "", false
+		}
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = encode(fmt.Sprint(v.Index(i).Interface()))
+		}
+		if !explode {
+			joined := strings.Join(items, ",")
+			if op.named {
+				return named(joined), true
+			}
+			return joined, true
+		}
+		if op.named {
+			for i := range items {
+				items[i] = named(items[i])
+			}
+		}
+		return strings.Join(items, op.sep), true
+	case reflect.Map:
+		if v.Len() == 0 {
+			return "", false
+		}
+		keys := make([]string, 0, v.Len())
+		values := map[string]string{}
+		for _, k := range v.MapKeys() {
+			key := fmt.Sprint(k.Interface())
+			keys = append(keys, key)
+			values[key] = fmt.Sprint(v.MapIndex(k).Interface())
+		}
+		sort.Strings(keys)
+		items := make([]string, 0, len(keys)*2)
+		for _, k := range keys {
+			if explode {
+				if values[k] == "" && op.named {
+					items = append(items, encode(k)+op.ifEmpty)
+				} else {
+					items = append(items, encode(k)+"="+encode(values[k]))
+				}
+			} else {
+				items = append(items, encode(k), encode(values[k]))
+			}
+		}
+		if explode {
+			return strings.Join(items, op.sep), true
+		}
+		joined := strings.Join(items, ",")
+		if op.named {
+			return named(joined), true
+		}
+		return joined, true
+	}
+
+	s := fmt.Sprint(v.Interface())
+	if v.Kind() == reflect.Slice {
+		s = string(v.Bytes())
+	}
+	if prefix > 0 && utf8.RuneCountInString(s) > prefix {
+		runes := []rune(s)
+		s = string(runes[:prefix])
+	}
+	if op.named {
+		return named(encode(s)), true
+	}
+	return encode(s), true
+}
+
+const (
+	templateUnreserved = "-._~"
+	templateReserved   = ":/?#[]@!$&'()*+,;="
+)
+
+// encodeTemplateValue percent-encodes a value, keeping reserved characters for + and # expansions
+func encodeTemplateValue(s string, reserved bool) string {
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case isTemplateAlnum(c) || strings.IndexByte(templateUnreserved, c) >= 0:
+			b.WriteByte(c)
+		case reserved && strings.IndexByte(templateReserved, c) >= 0:
+			b.WriteByte(c)
+		case reserved && c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			b.WriteString(s[i : i+3])
+			i += 2
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// encodeTemplateLiteral percent-encodes the characters of a literal which are not allowed in a uri
+func encodeTemplateLiteral(s string) string {
+	return encodeTemplateValue(s, true)
+}
+
+func isTemplateAlnum(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
@@ -0,0 +1,131 @@
+package okhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_ExpandURITemplate(t *testing.T) {
+	vars := map[string]interface{}{
+		"var":   "value",
+		"hello": "Hello World!",
+		"path":  "/foo/bar",
+		"empty": "",
+		"list":  []string{"red", "green", "blue"},
+		"keys":  map[string]string{"semi": ";", "dot": ".", "comma": ","},
+		"x":     1024,
+		"y":     768,
+	}
+	cases := map[string]string{
+		"{var}":          "value",
+		"{hello}":        "Hello%20World%21",
+		"{+path}/here":   "/foo/bar/here",
+		"{#path,x}/here": "#/foo/bar,1024/here",
+		"{var:3}":        "val",
+		"{list}":         "red,green,blue",
+		"{list*}":        "red,green,blue",
+		"{keys}":         "comma,%2C,dot,.,semi,%3B",
+		"{keys*}":        "comma=%2C,dot=.,semi=%3B",
+		"X{.list*}":      "X.red.green.blue",
+		"{/var,x}/here":  "/value/1024/here",
+		"{;x,y,empty}":   ";x=1024;y=768;empty",
+		"{?x,y,empty}":   "?x=1024&y=768&empty=",
+		"{?list*}":       "?list=red&list=green&list=blue",
+		"?fixed=yes{&x}": "?fixed=yes&x=1024",
+		"{?undef,x}":     "?x=1024",
+		"/users/{undef}": "/users/",
+		"{&keys*}":       "&comma=%2C&dot=.&semi=%3B",
+		"{+hello}":       "Hello%20World!",
+		"/a b/{var}":     "/a%20b/value",
+	}
+	for template, want := range cases {
+		got, err := ExpandURITemplate(template, vars)
+		if err != nil {
+			t.Errorf(`%s should expand, %v given`, template, err)
+			continue
+		}
+		if got != want {
+			t.Errorf(`%s should be "%s", "%s" given`, template, want, got)
+		}
+	}
+
+	for _, template := range []string{"{var", "var}", "{}", "{=var}", "{var:0}"} {
+		if _, err := ExpandURITemplate(template, vars); err == nil {
+			t.Errorf(`%s should be an error`, template)
+		}
+	}
+}
+
+type queryPage struct {
+	Page    int `query:"page"`
+	PerPage int `query:"per_page,omitempty"`
+}
+
+type queryStruct struct {
+	queryPage
+	Q       string            `query:"q"`
+	Tags    []string          `query:"tag"`
+	Filter  map[string]string `query:"filter"`
+	Since   time.Time         `query:"since,omitempty"`
+	Ignored string            `query:"-"`
+	Owner   *struct {
+		Name string `query:"name"`
+	} `query:"owner"`
+	Items []struct {
+		ID int `query:"id"`
+	} `query:"items"`
+}
+
+func Test_SetQueryStruct(t *testing.T) {
+	v := queryStruct{Q: "go", Tags: []string{"a", "b"}, Filter: map[string]string{"lang": "go"}, Ignored: "x"}
+	v.Page = 2
+	v.Owner = &struct {
+		Name string `query:"name"`
+	}{Name: "me"}
+	v.Items = append(v.Items, struct {
+		ID int `query:"id"`
+	}{ID: 7})
+
+	req, _ := Get("http://example.com/search?q=old&keep=1")
+	req.SetQueryStruct(v).AddQuery("tag", "c").SetQuery("keep", "2")
+	if req.Err() != nil {
+		t.Fatalf(`SetQueryStruct should have no error, %v given`, req.Err())
+	}
+	want := "filter%5Blang%5D=go&items%5B0%5D%5Bid%5D=7&keep=2&owner%5Bname%5D=me&page=2&q=go&tag=a&tag=b&tag=c"
+	if req.url.RawQuery != want {
+		t.Errorf(`query should be "%s", "%s" given`, want, req.url.RawQuery)
+	}
+
+	req, _ = Get("http://example.com/search")
+	if req.SetQueryStruct("bad").Err() == nil {
+		t.Errorf(`SetQueryStruct of a string should be an error`)
+	}
+}
+
+func Test_SetPathTemplate(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.EscapedPath() + "?" + r.URL.RawQuery))
+	}))
+	defer ts.Close()
+
+	req, _ := Get(ts.URL + "/api/?token=t")
+	req.SetPathTemplate("/users/{id}/repos{?page,per_page}", map[string]interface{}{"id": "a/b", "page": 3})
+	resp, err := req.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "/api/users/a%2Fb/repos?page=3&token=t"
+	if resp.String() != want {
+		t.Errorf(`url should be "%s", "%s" given`, want, resp.String())
+	}
+	if req.PathTemplate() != "/users/{id}/repos{?page,per_page}" {
+		t.Errorf(`PathTemplate should be "%s", "%s" given`, "/users/{id}/repos{?page,per_page}", req.PathTemplate())
+	}
+
+	req, _ = Get(ts.URL)
+	if req.SetPathTemplate("/users/{id", nil).Err() == nil {
+		t.Errorf(`SetPathTemplate of a bad template should be an error`)
+	}
+}
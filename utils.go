@@ -2,14 +2,20 @@ package okhttp
 
 import "net/http"
 
-// doHeader do http request header
+// doHeader do http request header, a single value is a string and a repeated header keeps all its values as []string
 func doHeader(headers http.Header) H {
 	var h = H{}
 	if headers == nil {
 		return h
 	}
-	for k := range headers {
-		h[k] = headers.Get(k)
+	for k, v := range headers {
+		switch len(v) {
+		case 0:
+		case 1:
+			h[k] = v[0]
+		default:
+			h[k] = append([]string(nil), v...)
+		}
 	}
 	return h
 }
@@ -0,0 +1,542 @@
+package okhttp
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType              = reflect.TypeOf(time.Time{})
+	textMarshalerType     = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	valuesMarshalerType   = reflect.TypeOf((*ValuesMarshaler)(nil)).Elem()
+	valuesUnmarshalerType = reflect.TypeOf((*ValuesUnmarshaler)(nil)).Elem()
+)
+
+// NestedStyle is the style of the keys of nested structs and maps in form and query values
+type NestedStyle int
+
+const (
+	// NestedBrackets encodes nested keys like a[b][0]
+	NestedBrackets NestedStyle = iota
+	// NestedDots encodes nested keys like a.b.0
+	NestedDots
+)
+
+// ValuesMarshaler is implemented by types which encode themselves into form or query values under key
+type ValuesMarshaler interface {
+	MarshalValues(key string, values url.Values) error
+}
+
+// ValuesUnmarshaler is implemented by types which decode themselves from the form or query values under key
+type ValuesUnmarshaler interface {
+	UnmarshalValues(key string, values url.Values) error
+}
+
+// valuesEncoder encodes structs to url.Values with the options of a struct tag like `query:"name,omitempty"`,
+// slices repeat their key and nested structs and maps use keys like a[b] or a.b.
+// time.Time is RFC3339 unless the field has a unix, unixmilli or unixnano option or a layout tag
+type valuesEncoder struct {
+	tag   string
+	style NestedStyle
+}
+
+// fieldOptions are the options of the struct field a value is encoded from
+type fieldOptions struct {
+	opts   tagOptions
+	layout string
+}
+
+// encode returns the values of a struct, a pointer to a struct or a map
+func (e valuesEncoder) encode(v interface{}) (url.Values, error) {
+	values := url.Values{}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return values, nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		return values, e.encodeStruct(values, "", rv)
+	case reflect.Map:
+		return values, e.encodeValue(values, "", rv, fieldOptions{})
+	}
+	return nil, fmt.Errorf("%s encoder: %T is not a struct or map", e.tag, v)
+}
+
+func (e valuesEncoder) encodeStruct(values url.Values, prefix string, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		name, opts := parseTag(field.Tag.Get(e.tag))
+		if name == "-" {
+			continue
+		}
+		fv := rv.Field(i)
+		if opts.has("omitempty") && fv.IsZero() {
+			continue
+		}
+
+		// embedded structs without name are flattened
+		if field.Anonymous && name == "" {
+			for fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					break
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && !isValuesScalar(fv.Type()) {
+				if err := e.encodeStruct(values, prefix, fv); err != nil {
+					return err
+				}
+				continue
+			}
+			if field.PkgPath != "" {
+				continue
+			}
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		fo := fieldOptions{opts: opts, layout: field.Tag.Get("layout")}
+		if err := e.encodeValue(values, e.key(prefix, name), fv, fo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e valuesEncoder) encodeValue(values url.Values, key string, rv reflect.Value, fo fieldOptions) error {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		if rv.Type().Implements(valuesMarshalerType) {
+			break
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Type().Implements(valuesMarshalerType) {
+		return rv.Interface().(ValuesMarshaler).MarshalValues(key, values)
+	}
+	if rv.CanAddr() && reflect.PtrTo(rv.Type()).Implements(valuesMarshalerType) {
+		return rv.Addr().Interface().(ValuesMarshaler).MarshalValues(key, values)
+	}
+	if s, ok, err := e.scalar(rv, fo); ok || err != nil {
+		if err != nil {
+			return fmt.Errorf("%s encoder: %s: %w", e.tag, key, err)
+		}
+		values.Add(key, s)
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			item := rv.Index(i)
+			for item.Kind() == reflect.Ptr || item.Kind() == reflect.Interface {
+				if item.IsNil() {
+					break
+				}
+				item = item.Elem()
+			}
+			itemKey := key
+			if item.Kind() == reflect.Struct && !isValuesScalar(item.Type()) || item.Kind() == reflect.Map {
+				itemKey = e.key(key, strconv.Itoa(i))
+			}
+			if err := e.encodeValue(values, itemKey, item, fo); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		keys := make([]string, 0, rv.Len())
+		items := map[string]reflect.Value{}
+		for _, k := range rv.MapKeys() {
+			name := fmt.Sprint(k.Interface())
+			keys = append(keys, name)
+			items[name] = rv.MapIndex(k)
+		}
+		sort.Strings(keys)
+		for _, name := range keys {
+			if err := e.encodeValue(values, e.key(key, name), items[name], fieldOptions{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Struct:
+		return e.encodeStruct(values, key, rv)
+	}
+	return fmt.Errorf("%s encoder: unsupported type %s of %s", e.tag, rv.Type(), key)
+}
+
+// scalar formats a value which is encoded as a single string
+func (e valuesEncoder) scalar(rv reflect.Value, fo fieldOptions) (string, bool, error) {
+	if rv.Type() == timeType {
+		return formatValuesTime(rv.Interface().(time.Time), fo), true, nil
+	}
+	if rv.Type().Implements(textMarshalerType) {
+		b, err := rv.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), true, err
+	}
+	if rv.CanAddr() && reflect.PtrTo(rv.Type()).Implements(textMarshalerType) {
+		b, err := rv.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), true, err
+	}
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), true, nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), true, nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, rv.Type().Bits()), true, nil
+	}
+	return "", false, nil
+}
+
+// key returns the key of a nested name
+func (e valuesEncoder) key(prefix, name string) string {
+	return nestedKey(e.style, prefix, name)
+}
+
+func nestedKey(style NestedStyle, prefix, name string) string {
+	switch {
+	case prefix == "":
+		return name
+	case style == NestedDots:
+		return prefix + "." + name
+	}
+	return prefix + "[" + name + "]"
+}
+
+// isValuesScalar reports the struct types which are encoded as a single value
+func isValuesScalar(t reflect.Type) bool {
+	return t == timeType || t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType)
+}
+
+func formatValuesTime(t time.Time, fo fieldOptions) string {
+	switch {
+	case fo.opts.has("unix"):
+		return strconv.FormatInt(t.Unix(), 10)
+	case fo.opts.has("unixmilli"):
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case fo.opts.has("unixnano"):
+		return strconv.FormatInt(t.UnixNano(), 10)
+	case fo.layout != "":
+		return t.Format(fo.layout)
+	}
+	return t.Format(time.RFC3339)
+}
+
+func parseValuesTime(s string, fo fieldOptions) (time.Time, error) {
+	var unit time.Duration
+	switch {
+	case fo.opts.has("unix"):
+		unit = time.Second
+	case fo.opts.has("unixmilli"):
+		unit = time.Millisecond
+	case fo.opts.has("unixnano"):
+		unit = time.Nanosecond
+	case fo.layout != "":
+		return time.Parse(fo.layout, s)
+	default:
+		return time.Parse(time.RFC3339, s)
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, 0).Add(time.Duration(n) * unit), nil
+}
+
+// valuesNode is a key of url.Values split at its nested names
+type valuesNode struct {
+	values   []string
+	children map[string]*valuesNode
+}
+
+func (n *valuesNode) child(name string) *valuesNode {
+	if n.children == nil {
+		n.children = map[string]*valuesNode{}
+	}
+	c, ok := n.children[name]
+	if !ok {
+		c = &valuesNode{}
+		n.children[name] = c
+	}
+	return c
+}
+
+// splitValuesKey splits a key like a[b][0], a.b.0 or a[] into its names
+func splitValuesKey(key string) []string {
+	var names []string
+	for _, part := range strings.Split(key, ".") {
+		i := strings.IndexByte(part, '[')
+		if i < 0 || !strings.HasSuffix(part, "]") {
+			names = append(names, part)
+			continue
+		}
+		names = append(names, part[:i])
+		names = append(names, strings.Split(part[i+1:len(part)-1], "][")...)
+	}
+	if len(names) > 1 && names[len(names)-1] == "" {
+		names = names[:len(names)-1]
+	}
+	return names
+}
+
+// valuesDecoder decodes url.Values into structs with the tags of valuesEncoder,
+// nested keys are accepted in both the a[b] and a.b styles
+type valuesDecoder struct {
+	tag   string
+	style NestedStyle
+}
+
+// decode decodes values into v, which must be a pointer to a struct or a map
+func (d valuesDecoder) decode(values url.Values, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("%s decoder: %T is not a non-nil pointer", d.tag, v)
+	}
+	root := &valuesNode{}
+	for key, vs := range values {
+		n := root
+		for _, name := range splitValuesKey(key) {
+			n = n.child(name)
+		}
+		n.values = append(n.values, vs...)
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct && rv.Kind() != reflect.Map {
+		return fmt.Errorf("%s decoder: %T is not a pointer to a struct or map", d.tag, v)
+	}
+	return d.decodeValue(values, "", root, rv, fieldOptions{})
+}
+
+func (d valuesDecoder) decodeStruct(values url.Values, prefix string, n *valuesNode, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		name, opts := parseTag(field.Tag.Get(d.tag))
+		if name == "-" {
+			continue
+		}
+		fv := rv.Field(i)
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !isValuesScalar(ft) {
+				if fv.Kind() == reflect.Ptr {
+					if field.PkgPath != "" {
+						continue
+					}
+					if fv.IsNil() {
+						fv.Set(reflect.New(ft))
+					}
+					fv = fv.Elem()
+				}
+				if err := d.decodeStruct(values, prefix, n, fv); err != nil {
+					return err
+				}
+				continue
+			}
+			if field.PkgPath != "" {
+				continue
+			}
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		c, ok := n.children[name]
+		if !ok {
+			continue
+		}
+		fo := fieldOptions{opts: opts, layout: field.Tag.Get("layout")}
+		if err := d.decodeValue(values, nestedKey(d.style, prefix, name), c, fv, fo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d valuesDecoder) decodeValue(values url.Values, key string, n *valuesNode, rv reflect.Value, fo fieldOptions) error {
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return d.decodeValue(values, key, n, rv.Elem(), fo)
+	}
+	if rv.CanAddr() {
+		if u, ok := rv.Addr().Interface().(ValuesUnmarshaler); ok {
+			return u.UnmarshalValues(key, values)
+		}
+	}
+
+	switch {
+	case rv.Type() == timeType:
+		if len(n.values) == 0 {
+			return nil
+		}
+		t, err := parseValuesTime(n.values[0], fo)
+		if err != nil {
+			return fmt.Errorf("%s decoder: %s: %w", d.tag, key, err)
+		}
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	case rv.CanAddr() && reflect.PtrTo(rv.Type()).Implements(textUnmarshalerType):
+		if len(n.values) == 0 {
+			return nil
+		}
+		if err := rv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(n.values[0])); err != nil {
+			return fmt.Errorf("%s decoder: %s: %w", d.tag, key, err)
+		}
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		return d.decodeStruct(values, key, n, rv)
+	case reflect.Map:
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(rv.Type()))
+		}
+		for name, c := range n.children {
+			k := reflect.New(rv.Type().Key()).Elem()
+			if err := d.scalar(k, name); err != nil {
+				return fmt.Errorf("%s decoder: %s: %w", d.tag, key, err)
+			}
+			item := reflect.New(rv.Type().Elem()).Elem()
+			if err := d.decodeValue(values, nestedKey(d.style, key, name), c, item, fo); err != nil {
+				return err
+			}
+			rv.SetMapIndex(k, item)
+		}
+		return nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		// indexed children like a[0][b] come first, then repeated keys
+		indexes := make([]int, 0, len(n.children))
+		for name := range n.children {
+			i, err := strconv.Atoi(name)
+			if err != nil {
+				continue
+			}
+			indexes = append(indexes, i)
+		}
+		sort.Ints(indexes)
+		items := reflect.MakeSlice(rv.Type(), 0, len(indexes)+len(n.values))
+		for _, i := range indexes {
+			item := reflect.New(rv.Type().Elem()).Elem()
+			name := strconv.Itoa(i)
+			if err := d.decodeValue(values, nestedKey(d.style, key, name), n.children[name], item, fo); err != nil {
+				return err
+			}
+			items = reflect.Append(items, item)
+		}
+		for _, s := range n.values {
+			item := reflect.New(rv.Type().Elem()).Elem()
+			if err := d.decodeValue(values, key, &valuesNode{values: []string{s}}, item, fo); err != nil {
+				return err
+			}
+			items = reflect.Append(items, item)
+		}
+		rv.Set(items)
+		return nil
+	}
+
+	if len(n.values) == 0 {
+		return nil
+	}
+	if err := d.scalar(rv, n.values[0]); err != nil {
+		return fmt.Errorf("%s decoder: %s: %w", d.tag, key, err)
+	}
+	return nil
+}
+
+// scalar parses s into a value of a basic kind
+func (d valuesDecoder) scalar(rv reflect.Value, s string) error {
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(s)
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", rv.Type())
+		}
+		rv.SetBytes([]byte(s))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		rv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, err := strconv.ParseUint(s, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(f)
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			return fmt.Errorf("unsupported type %s", rv.Type())
+		}
+		rv.Set(reflect.ValueOf(s))
+	default:
+		return fmt.Errorf("unsupported type %s", rv.Type())
+	}
+	return nil
+}
+
+// tagOptions are the options following the name of a struct tag
+type tagOptions []string
+
+func parseTag(tag string) (string, tagOptions) {
+	parts := strings.Split(tag, ",")
+	return parts[0], tagOptions(parts[1:])
+}
+
+func (o tagOptions) has(name string) bool {
+	for _, opt := range o {
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
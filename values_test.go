@@ -0,0 +1,137 @@
+package okhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type formColor struct {
+	R, G, B uint8
+}
+
+func (c formColor) MarshalText() ([]byte, error) {
+	return []byte{'#', "0123456789abcdef"[c.R>>4], "0123456789abcdef"[c.R&15]}, nil
+}
+
+func (c *formColor) UnmarshalText(b []byte) error {
+	if len(b) != 3 || b[0] != '#' {
+		return url.EscapeError(string(b))
+	}
+	c.R = uint8(strings.IndexByte("0123456789abcdef", b[1])<<4 | strings.IndexByte("0123456789abcdef", b[2]))
+	return nil
+}
+
+type formRange struct {
+	From, To int
+}
+
+func (r formRange) MarshalValues(key string, values url.Values) error {
+	values.Set(key, strconv.Itoa(r.From)+"-"+strconv.Itoa(r.To))
+	return nil
+}
+
+type formAddress struct {
+	City string `form:"city"`
+	Zip  string `form:"zip,omitempty"`
+}
+
+type formStruct struct {
+	Name      string            `form:"name"`
+	Age       int               `form:"age,omitempty"`
+	Admin     bool              `form:"admin"`
+	Tags      []string          `form:"tags"`
+	Address   formAddress       `form:"address"`
+	Addresses []formAddress     `form:"addresses"`
+	Meta      map[string]string `form:"meta"`
+	Created   time.Time         `form:"created,unix"`
+	Day       time.Time         `form:"day" layout:"2006-01-02"`
+	Color     formColor         `form:"color"`
+	Score     *float64          `form:"score"`
+}
+
+func Test_SetFormStruct(t *testing.T) {
+	score := 9.5
+	v := formStruct{
+		Name:      "foo",
+		Admin:     true,
+		Tags:      []string{"a", "b"},
+		Address:   formAddress{City: "x"},
+		Addresses: []formAddress{{City: "y", Zip: "1"}},
+		Meta:      map[string]string{"k": "v"},
+		Created:   time.Unix(1700000000, 0),
+		Day:       time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		Color:     formColor{R: 0xab},
+		Score:     &score,
+	}
+
+	var body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		body = r.PostForm.Encode()
+		w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	styles := map[NestedStyle]string{
+		NestedBrackets: "address%5Bcity%5D=x&addresses%5B0%5D%5Bcity%5D=y&addresses%5B0%5D%5Bzip%5D=1&admin=true&color=%23ab&created=1700000000&day=2024-01-02&meta%5Bk%5D=v&name=foo&score=9.5&tags=a&tags=b",
+		NestedDots:     "address.city=x&addresses.0.city=y&addresses.0.zip=1&admin=true&color=%23ab&created=1700000000&day=2024-01-02&meta.k=v&name=foo&score=9.5&tags=a&tags=b",
+	}
+	for style, want := range styles {
+		req, _ := Post(ts.URL)
+		resp, err := req.SetNestedStyle(style).SetFormStruct(v).Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != want {
+			t.Errorf(`form should be "%s", "%s" given`, want, body)
+		}
+
+		var got formStruct
+		if err := resp.GetForm(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != v.Name || !got.Admin || len(got.Tags) != 2 || got.Address != v.Address ||
+			len(got.Addresses) != 1 || got.Addresses[0] != v.Addresses[0] || got.Meta["k"] != "v" ||
+			!got.Created.Equal(v.Created) || !got.Day.Equal(v.Day) || got.Color != v.Color || *got.Score != score {
+			t.Errorf(`GetForm should be %+v, %+v given`, v, got)
+		}
+
+		var decoded formStruct
+		if err := resp.Decode(&decoded); err != nil || decoded.Name != "foo" {
+			t.Errorf(`Decode should use the form codec, %v given`, err)
+		}
+	}
+}
+
+func Test_ValuesMarshaler(t *testing.T) {
+	values, err := valuesEncoder{tag: "query"}.encode(struct {
+		Range formRange `query:"range"`
+	}{formRange{1, 5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values.Get("range") != "1-5" {
+		t.Errorf(`range should be "%s", "%s" given`, "1-5", values.Get("range"))
+	}
+}
+
+func Test_GetFormErrors(t *testing.T) {
+	resp := &Response{body: []byte("age=old")}
+	var v formStruct
+	if err := resp.GetForm(&v); err == nil {
+		t.Errorf(`GetForm of a bad int should be an error`)
+	}
+	if err := resp.GetForm(v); err == nil {
+		t.Errorf(`GetForm to a non pointer should be an error`)
+	}
+	var values url.Values
+	if err := resp.GetForm(&values); err != nil || values.Get("age") != "old" {
+		t.Errorf(`GetForm to url.Values should be "%s", "%s" given`, "old", values.Get("age"))
+	}
+}
@@ -0,0 +1,533 @@
+package okhttp
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// message types of a WebSocket
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+// close codes of a WebSocket, see RFC 6455 section 7.4
+const (
+	CloseNormalClosure      = 1000
+	CloseGoingAway          = 1001
+	CloseProtocolError      = 1002
+	CloseUnsupportedData    = 1003
+	CloseNoStatusReceived   = 1005
+	CloseAbnormalClosure    = 1006
+	CloseInvalidPayloadData = 1007
+	ClosePolicyViolation    = 1008
+	CloseMessageTooBig      = 1009
+	CloseInternalServerErr  = 1011
+)
+
+var (
+	// WebSocketClosed is returned when sending on a closing or closed WebSocket
+	WebSocketClosed = errors.New("websocket is closed")
+	// WebSocketPongTimeout is reported when a ping is not answered before the next one
+	WebSocketPongTimeout = errors.New("websocket ping is not answered by a pong")
+	// WebSocketMessageTooBig is reported when a received message is larger than the max message size,
+	// the WebSocket is closed with CloseMessageTooBig
+	WebSocketMessageTooBig = errors.New("websocket message is too big")
+)
+
+// DefaultWebSocketMaxMessageSize is the max size of a received message, after its fragments are
+// reassembled and it's decompressed
+var DefaultWebSocketMaxMessageSize int64 = 32 << 20
+
+// webSocketCloseTimeout is how long Close waits for the close reply of the server
+var webSocketCloseTimeout = 60 * time.Second
+
+// webSocketMinDeflateSize is the smallest message compressed by permessage-deflate
+const webSocketMinDeflateSize = 1024
+
+// WebSocketListener receives the events of a WebSocket, every callback runs on its reader goroutine
+type WebSocketListener interface {
+	// OnOpen is called when the handshake succeeded
+	OnOpen(ws *WebSocket, resp *Response)
+	// OnMessage is called for every TextMessage or BinaryMessage
+	OnMessage(ws *WebSocket, messageType int, data []byte)
+	// OnClosing is called when the server sent a close frame
+	OnClosing(ws *WebSocket, code int, reason string)
+	// OnClosed is called when both sides closed and the connection is released
+	OnClosed(ws *WebSocket, code int, reason string)
+	// OnFailure is called when the handshake or the connection failed, resp is only set by a failed handshake
+	OnFailure(ws *WebSocket, err error, resp *Response)
+}
+
+// WebSocketListenerBase implements every callback of WebSocketListener as a no-op, embed it to pick callbacks
+type WebSocketListenerBase struct{}
+
+func (WebSocketListenerBase) OnOpen(*WebSocket, *Response)           {}
+func (WebSocketListenerBase) OnMessage(*WebSocket, int, []byte)      {}
+func (WebSocketListenerBase) OnClosing(*WebSocket, int, string)      {}
+func (WebSocketListenerBase) OnClosed(*WebSocket, int, string)       {}
+func (WebSocketListenerBase) OnFailure(*WebSocket, error, *Response) {}
+
+// WebSocket is a client WebSocket connection
+type WebSocket struct {
+	request  *Request
+	listener WebSocketListener
+	conn     io.ReadWriteCloser
+	br       *bufio.Reader
+	cancel   context.CancelFunc
+
+	maxMessageSize int64
+
+	writeMu  sync.Mutex
+	deflater *wsDeflater
+	inflater *wsInflater
+
+	mu           sync.Mutex
+	closeSent    bool
+	closed       bool
+	awaitingPong bool
+	done         chan struct{}
+}
+
+// SetPingInterval set the interval of the pings keeping WebSockets alive, zero disables them
+func (c *Client) SetPingInterval(d time.Duration) *Client {
+	c.pingInterval = d
+	return c
+}
+
+// SetWebSocketCompression enables permessage-deflate for WebSockets, it's enabled by default
+func (c *Client) SetWebSocketCompression(enable bool) *Client {
+	c.disableWebSocketDeflate = !enable
+	return c
+}
+
+// SetWebSocketMaxMessageSize set the max size of the messages received by WebSockets,
+// zero uses DefaultWebSocketMaxMessageSize
+func (c *Client) SetWebSocketMaxMessageSize(n int64) *Client {
+	c.maxMessageSize = n
+	return c
+}
+
+// NewWebSocket opens a WebSocket to a ws:// or wss:// request, reusing its headers, cookies, auth and proxy,
+// a failed handshake is reported to OnFailure and returned
+func (c *Client) NewWebSocket(r *Request, listener WebSocketListener) (*WebSocket, error) {
+	ws := &WebSocket{request: r, listener: listener, maxMessageSize: c.maxMessageSize, done: make(chan struct{})}
+	if ws.maxMessageSize <= 0 {
+		ws.maxMessageSize = DefaultWebSocketMaxMessageSize
+	}
+	resp, err := ws.connect(!c.disableWebSocketDeflate)
+	if err != nil {
+		listener.OnFailure(ws, err, resp)
+		return nil, err
+	}
+	listener.OnOpen(ws, resp)
+	go ws.readLoop()
+	if c.pingInterval > 0 {
+		go ws.pingLoop(c.pingInterval)
+	}
+	return ws, nil
+}
+
+// connect runs the opening handshake
+func (ws *WebSocket) connect(deflate bool) (*Response, error) {
+	if err := ws.request.Err(); err != nil {
+		return nil, err
+	}
+	// the handshake is an HTTP/1.1 request without client time out, it would kill the connection
+	hs := *ws.request
+	hs.protocol = ProtocolHTTP1
+	hs.timeout = 0
+	u := *ws.request.url
+	switch strings.ToLower(u.Scheme) {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+	hs.url = &u
+	hs.header = ws.request.header.Clone()
+
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	challenge := base64.StdEncoding.EncodeToString(key)
+	hs.header.Set("Connection", "Upgrade")
+	hs.header.Set("Upgrade", "websocket")
+	hs.header.Set("Sec-WebSocket-Version", "13")
+	hs.header.Set("Sec-WebSocket-Key", challenge)
+	if deflate {
+		hs.header.Set("Sec-WebSocket-Extensions", "permessage-deflate")
+	}
+
+	client, err := hs.client()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ws.request.Context())
+	request, err := hs.httpRequest(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	request.Method = http.MethodGet
+	if ws.request.timeout > 0 {
+		timer := time.AfterFunc(ws.request.timeout, cancel)
+		defer timer.Stop()
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		defer cancel()
+		defer response.Body.Close()
+		resp, err := hs.response(response)
+		if err != nil {
+			return nil, err
+		}
+		return resp, fmt.Errorf("websocket: expected status 101 but was %d", response.StatusCode)
+	}
+
+	resp := &Response{
+		request: ws.request,
+		status:  response.StatusCode,
+		proto:   response.Proto,
+		headers: response.Header,
+		cookies: response.Cookies(),
+	}
+	fail := func(err error) (*Response, error) {
+		response.Body.Close()
+		cancel()
+		return resp, err
+	}
+	if !strings.EqualFold(response.Header.Get("Upgrade"), "websocket") {
+		return fail(errors.New("websocket: expected Upgrade header websocket"))
+	}
+	if !headerContainsToken(response.Header, "Connection", "upgrade") {
+		return fail(errors.New("websocket: expected Connection header Upgrade"))
+	}
+	if response.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(challenge) {
+		return fail(errors.New("websocket: bad Sec-WebSocket-Accept header"))
+	}
+	if ext := response.Header.Get("Sec-WebSocket-Extensions"); ext != "" {
+		params, ok := parseDeflateExtension(ext)
+		if !deflate || !ok {
+			return fail(errors.New("websocket: unexpected extension " + ext))
+		}
+		ws.deflater = newWsDeflater(params["client_no_context_takeover"])
+		ws.inflater = newWsInflater(params["server_no_context_takeover"])
+	}
+
+	conn, ok := response.Body.(io.ReadWriteCloser)
+	if !ok {
+		return fail(errors.New("websocket: connection is not writable"))
+	}
+	ws.conn = conn
+	ws.br = bufio.NewReader(conn)
+	ws.cancel = cancel
+	return resp, nil
+}
+
+// Request returns the request which opened the WebSocket
+func (ws *WebSocket) Request() *Request {
+	return ws.request
+}
+
+// Send sends a text message
+func (ws *WebSocket) Send(text string) error {
+	return ws.send(TextMessage, []byte(text))
+}
+
+// SendBinary sends a binary message
+func (ws *WebSocket) SendBinary(data []byte) error {
+	return ws.send(BinaryMessage, data)
+}
+
+func (ws *WebSocket) send(opcode byte, data []byte) error {
+	ws.mu.Lock()
+	closing := ws.closeSent || ws.closed
+	ws.mu.Unlock()
+	if closing {
+		return WebSocketClosed
+	}
+
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	rsv1 := false
+	if ws.deflater != nil && len(data) >= webSocketMinDeflateSize {
+		compressed, err := ws.deflater.compress(data)
+		if err != nil {
+			return err
+		}
+		data, rsv1 = compressed, true
+	}
+	return writeWsFrame(ws.conn, true, rsv1, opcode, data, true)
+}
+
+// Close starts the closing handshake, the connection is released when the server answers
+// or after a time out
+func (ws *WebSocket) Close(code int, reason string) error {
+	if !validCloseCode(code) {
+		return fmt.Errorf("websocket: invalid close code %d", code)
+	}
+	if len(reason) > 123 {
+		return errors.New("websocket: close reason is longer than 123 bytes")
+	}
+	ws.mu.Lock()
+	if ws.closeSent || ws.closed {
+		ws.mu.Unlock()
+		return WebSocketClosed
+	}
+	ws.closeSent = true
+	ws.mu.Unlock()
+
+	if err := ws.writeControl(opClose, closePayload(code, reason)); err != nil {
+		ws.Cancel()
+		return err
+	}
+	go func() {
+		select {
+		case <-ws.done:
+		case <-time.After(webSocketCloseTimeout):
+			ws.Cancel()
+		}
+	}()
+	return nil
+}
+
+// Cancel releases the connection at once without closing handshake
+func (ws *WebSocket) Cancel() {
+	ws.conn.Close()
+	ws.cancel()
+}
+
+func (ws *WebSocket) writeControl(opcode byte, payload []byte) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	return writeWsFrame(ws.conn, true, false, opcode, payload, true)
+}
+
+func (ws *WebSocket) pingLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ws.done:
+			return
+		case <-ticker.C:
+		}
+		ws.mu.Lock()
+		if ws.closed {
+			ws.mu.Unlock()
+			return
+		}
+		missed := ws.awaitingPong
+		ws.awaitingPong = true
+		ws.mu.Unlock()
+		if missed {
+			ws.fail(WebSocketPongTimeout)
+			return
+		}
+		if err := ws.writeControl(opPing, nil); err != nil {
+			ws.fail(err)
+			return
+		}
+	}
+}
+
+// fail releases the connection and reports err to the listener once
+func (ws *WebSocket) fail(err error) {
+	ws.mu.Lock()
+	if ws.closed {
+		ws.mu.Unlock()
+		return
+	}
+	ws.closed = true
+	ws.mu.Unlock()
+	ws.Cancel()
+	close(ws.done)
+	ws.listener.OnFailure(ws, err, nil)
+}
+
+// tooBig closes the WebSocket with CloseMessageTooBig and reports WebSocketMessageTooBig
+func (ws *WebSocket) tooBig() {
+	ws.mu.Lock()
+	send := !ws.closeSent
+	ws.closeSent = true
+	ws.mu.Unlock()
+	if send {
+		ws.writeControl(opClose, closePayload(CloseMessageTooBig, "message too big"))
+	}
+	ws.fail(WebSocketMessageTooBig)
+}
+
+func (ws *WebSocket) readLoop() {
+	var (
+		message    []byte
+		messageOp  byte
+		compressed bool
+	)
+	for {
+		f, err := readWsFrame(ws.br, false)
+		if err != nil {
+			ws.fail(err)
+			return
+		}
+
+		switch f.opcode {
+		case opPing:
+			if err := ws.writeControl(opPong, f.payload); err != nil {
+				ws.fail(err)
+				return
+			}
+			continue
+		case opPong:
+			ws.mu.Lock()
+			ws.awaitingPong = false
+			ws.mu.Unlock()
+			continue
+		case opClose:
+			ws.closing(f.payload)
+			return
+		case opContinuation:
+			if messageOp == 0 {
+				ws.fail(errors.New("websocket: unexpected continuation frame"))
+				return
+			}
+		default:
+			if messageOp != 0 {
+				ws.fail(errors.New("websocket: expected continuation frame"))
+				return
+			}
+			messageOp, compressed = f.opcode, f.rsv1
+		}
+		if f.rsv1 && (f.opcode == opContinuation || ws.inflater == nil) {
+			ws.fail(errors.New("websocket: unexpected rsv1 bit"))
+			return
+		}
+
+		if int64(len(message))+int64(len(f.payload)) > ws.maxMessageSize {
+			ws.tooBig()
+			return
+		}
+		message = append(message, f.payload...)
+		if !f.fin {
+			continue
+		}
+		if compressed {
+			if message, err = ws.inflater.decompress(message, ws.maxMessageSize); err == WebSocketMessageTooBig {
+				ws.tooBig()
+				return
+			} else if err != nil {
+				ws.fail(err)
+				return
+			}
+		}
+		if messageOp == TextMessage && !utf8.Valid(message) {
+			ws.fail(errors.New("websocket: invalid utf-8 text message"))
+			return
+		}
+		ws.listener.OnMessage(ws, int(messageOp), message)
+		message, messageOp, compressed = nil, 0, false
+	}
+}
+
+// closing answers the close frame of the server and releases the connection
+func (ws *WebSocket) closing(payload []byte) {
+	code, reason := CloseNoStatusReceived, ""
+	if len(payload) >= 2 {
+		code, reason = int(binary.BigEndian.Uint16(payload)), string(payload[2:])
+	}
+	ws.listener.OnClosing(ws, code, reason)
+
+	ws.mu.Lock()
+	reply := !ws.closeSent
+	ws.closeSent = true
+	ws.mu.Unlock()
+	if reply {
+		echo := CloseNormalClosure
+		if code != CloseNoStatusReceived {
+			echo = code
+		}
+		ws.writeControl(opClose, closePayload(echo, ""))
+	}
+
+	ws.mu.Lock()
+	if ws.closed {
+		ws.mu.Unlock()
+		return
+	}
+	ws.closed = true
+	ws.mu.Unlock()
+	ws.Cancel()
+	close(ws.done)
+	ws.listener.OnClosed(ws, code, reason)
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code < 1000 || code >= 5000:
+		return false
+	case code == 1004 || code == 1005 || code == 1006 || (code > 1011 && code < 3000):
+		return false
+	}
+	return true
+}
+
+func closePayload(code int, reason string) []byte {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return append(payload, reason...)
+}
+
+func webSocketAccept(challenge string) string {
+	h := sha1.New()
+	h.Write([]byte(challenge + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContainsToken reports whether a comma separated header contains token
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, v := range header.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseDeflateExtension parses a negotiated permessage-deflate extension
+func parseDeflateExtension(ext string) (map[string]bool, bool) {
+	parts := strings.Split(ext, ";")
+	if strings.TrimSpace(parts[0]) != "permessage-deflate" {
+		return nil, false
+	}
+	params := map[string]bool{}
+	for _, p := range parts[1:] {
+		name := strings.TrimSpace(strings.SplitN(p, "=", 2)[0])
+		switch name {
+		case "client_no_context_takeover", "server_no_context_takeover", "server_max_window_bits":
+			params[name] = true
+		default:
+			return nil, false
+		}
+	}
+	return params, true
+}
@@ -0,0 +1,211 @@
+package okhttp
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// opcodes of WebSocket frames
+const (
+	opContinuation byte = 0x0
+	opText         byte = 0x1
+	opBinary       byte = 0x2
+	opClose        byte = 0x8
+	opPing         byte = 0x9
+	opPong         byte = 0xa
+)
+
+// maxWsFrameSize limits the payload of a single frame
+const maxWsFrameSize = 16 << 20
+
+type wsFrame struct {
+	fin     bool
+	rsv1    bool
+	opcode  byte
+	payload []byte
+}
+
+// writeWsFrame writes a frame, clients mask every frame they send
+func writeWsFrame(w io.Writer, fin, rsv1 bool, opcode byte, payload []byte, mask bool) error {
+	header := make([]byte, 2, 14)
+	header[0] = opcode
+	if fin {
+		header[0] |= 0x80
+	}
+	if rsv1 {
+		header[0] |= 0x40
+	}
+
+	n := len(payload)
+	switch {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = append(header, byte(n>>8), byte(n))
+	default:
+		header[1] = 127
+		header = header[:10]
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+
+	if mask {
+		header[1] |= 0x80
+		key := make([]byte, 4)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		header = append(header, key...)
+		masked := make([]byte, n)
+		for i := range payload {
+			masked[i] = payload[i] ^ key[i%4]
+		}
+		payload = masked
+	}
+
+	if _, err := w.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readWsFrame reads a frame, servers expect masked frames and clients unmasked ones
+func readWsFrame(br *bufio.Reader, masked bool) (*wsFrame, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, err
+	}
+	f := &wsFrame{
+		fin:    header[0]&0x80 != 0,
+		rsv1:   header[0]&0x40 != 0,
+		opcode: header[0] & 0x0f,
+	}
+	if header[0]&0x30 != 0 {
+		return nil, errors.New("websocket: unexpected rsv2 or rsv3 bit")
+	}
+	if (header[1]&0x80 != 0) != masked {
+		return nil, errors.New("websocket: unexpected frame masking")
+	}
+
+	n := uint64(header[1] & 0x7f)
+	switch n {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(br, ext); err != nil {
+			return nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(br, ext); err != nil {
+			return nil, err
+		}
+		n = binary.BigEndian.Uint64(ext)
+	}
+
+	switch f.opcode {
+	case opContinuation, opText, opBinary:
+		if n > maxWsFrameSize {
+			return nil, errors.New("websocket: frame too large")
+		}
+	case opClose, opPing, opPong:
+		if n > 125 || !f.fin {
+			return nil, errors.New("websocket: invalid control frame")
+		}
+		if f.rsv1 {
+			return nil, errors.New("websocket: compressed control frame")
+		}
+	default:
+		return nil, errors.New("websocket: unknown opcode")
+	}
+
+	var key []byte
+	if masked {
+		key = make([]byte, 4)
+		if _, err := io.ReadFull(br, key); err != nil {
+			return nil, err
+		}
+	}
+	f.payload = make([]byte, n)
+	if _, err := io.ReadFull(br, f.payload); err != nil {
+		return nil, err
+	}
+	if masked {
+		for i := range f.payload {
+			f.payload[i] ^= key[i%4]
+		}
+	}
+	return f, nil
+}
+
+// deflateTail ends a sync flushed deflate stream, see RFC 7692 section 7.2
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+// wsDeflater compresses messages for permessage-deflate
+type wsDeflater struct {
+	buf        bytes.Buffer
+	w          *flate.Writer
+	noTakeover bool
+}
+
+func newWsDeflater(noContextTakeover bool) *wsDeflater {
+	d := &wsDeflater{noTakeover: noContextTakeover}
+	d.w, _ = flate.NewWriter(&d.buf, flate.DefaultCompression)
+	return d
+}
+
+func (d *wsDeflater) compress(data []byte) ([]byte, error) {
+	d.buf.Reset()
+	if d.noTakeover {
+		d.w.Reset(&d.buf)
+	}
+	if _, err := d.w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := d.w.Flush(); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), bytes.TrimSuffix(d.buf.Bytes(), deflateTail)...), nil
+}
+
+// wsInflater decompresses messages for permessage-deflate,
+// with context takeover the last 32KB of output are the dictionary of the next message
+type wsInflater struct {
+	r          io.ReadCloser
+	dict       []byte
+	noTakeover bool
+}
+
+// deflateEnd is deflateTail followed by a final empty stored block
+var deflateEnd = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+func newWsInflater(noContextTakeover bool) *wsInflater {
+	return &wsInflater{r: flate.NewReader(nil), noTakeover: noContextTakeover}
+}
+
+// decompress inflates a message, failing with WebSocketMessageTooBig when it's larger than limit
+func (i *wsInflater) decompress(data []byte, limit int64) ([]byte, error) {
+	src := io.MultiReader(bytes.NewReader(data), bytes.NewReader(deflateEnd))
+	if err := i.r.(flate.Resetter).Reset(src, i.dict); err != nil {
+		return nil, err
+	}
+	out, err := io.ReadAll(io.LimitReader(i.r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) > limit {
+		return nil, WebSocketMessageTooBig
+	}
+	if !i.noTakeover {
+		i.dict = append(i.dict, out...)
+		if len(i.dict) > 32<<10 {
+			i.dict = append([]byte(nil), i.dict[len(i.dict)-32<<10:]...)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,309 @@
+package okhttp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newWebSocketServer runs an echo server, it answers pings unless mute is set
+func newWebSocketServer(t *testing.T, pings *int32, mute bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			t.Errorf(`Handshake should carry the Authorization header`)
+		}
+		if c, err := r.Cookie("session"); err != nil || c.Value != "abc" {
+			t.Errorf(`Handshake should carry the session cookie, %v given`, err)
+		}
+
+		deflate := strings.HasPrefix(r.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		rw.WriteString("Sec-WebSocket-Accept: " + webSocketAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n")
+		if deflate {
+			rw.WriteString("Sec-WebSocket-Extensions: permessage-deflate\r\n")
+		}
+		rw.WriteString("\r\n")
+		rw.Flush()
+
+		inflater, deflater := newWsInflater(false), newWsDeflater(false)
+		br := bufio.NewReader(conn)
+		for {
+			f, err := readWsFrame(br, true)
+			if err != nil {
+				return
+			}
+			switch f.opcode {
+			case opPing:
+				atomic.AddInt32(pings, 1)
+				if !mute {
+					writeWsFrame(conn, true, false, opPong, f.payload, false)
+				}
+			case opClose:
+				writeWsFrame(conn, true, false, opClose, f.payload, false)
+				return
+			case opText, opBinary:
+				payload := f.payload
+				if f.rsv1 {
+					if payload, err = inflater.decompress(payload, DefaultWebSocketMaxMessageSize); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+				rsv1 := deflate && len(payload) >= webSocketMinDeflateSize
+				if rsv1 {
+					payload, _ = deflater.compress(payload)
+				}
+				writeWsFrame(conn, true, rsv1, f.opcode, payload, false)
+			}
+		}
+	}))
+}
+
+type recordListener struct {
+	WebSocketListenerBase
+	messages chan string
+	closed   chan int
+	failed   chan error
+}
+
+func newRecordListener() *recordListener {
+	return &recordListener{
+		messages: make(chan string, 10),
+		closed:   make(chan int, 1),
+		failed:   make(chan error, 1),
+	}
+}
+
+func (l *recordListener) OnMessage(ws *WebSocket, messageType int, data []byte) {
+	l.messages <- string(data)
+}
+
+func (l *recordListener) OnClosed(ws *WebSocket, code int, reason string) {
+	l.closed <- code
+}
+
+func (l *recordListener) OnFailure(ws *WebSocket, err error, resp *Response) {
+	l.failed <- err
+}
+
+func Test_WebSocketEcho(t *testing.T) {
+	var pings int32
+	ts := newWebSocketServer(t, &pings, false)
+	defer ts.Close()
+
+	client := NewClient()
+	req, _ := client.Get("ws" + strings.TrimPrefix(ts.URL, "http"))
+	req.SetBasicAuth("user", "pass").SetCookie(&http.Cookie{Name: "session", Value: "abc"})
+
+	l := newRecordListener()
+	ws, err := client.NewWebSocket(req, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws.deflater == nil {
+		t.Errorf(`permessage-deflate should be negotiated`)
+	}
+
+	large := strings.Repeat("okhttp ", 1000)
+	ws.Send("hello")
+	ws.SendBinary([]byte(large))
+	ws.Send(large + "again")
+	for _, want := range []string{"hello", large, large + "again"} {
+		select {
+		case got := <-l.messages:
+			if got != want {
+				t.Errorf(`Message should be "%.20s", "%.20s" given`, want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("message not received")
+		}
+	}
+
+	if err := ws.Close(CloseNormalClosure, "bye"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case code := <-l.closed:
+		if code != CloseNormalClosure {
+			t.Errorf(`Close code should be %d, %d given`, CloseNormalClosure, code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("websocket not closed")
+	}
+	if err := ws.Send("late"); err != WebSocketClosed {
+		t.Errorf(`Send after close should fail with %v, %v given`, WebSocketClosed, err)
+	}
+}
+
+func Test_WebSocketTracer(t *testing.T) {
+	var pings int32
+	ts := newWebSocketServer(t, &pings, false)
+	defer ts.Close()
+
+	exporter := NewInMemoryExporter()
+	client := NewClient().SetTracer(NewTracer(exporter))
+	req, _ := client.Get("ws" + strings.TrimPrefix(ts.URL, "http"))
+	req.SetBasicAuth("user", "pass").SetCookie(&http.Cookie{Name: "session", Value: "abc"})
+
+	l := newRecordListener()
+	ws, err := client.NewWebSocket(req, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close(CloseNormalClosure, "bye")
+	ws.Send("hello")
+	select {
+	case got := <-l.messages:
+		if got != "hello" {
+			t.Errorf(`Message should be "%s", "%s" given`, "hello", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+	}
+	spans := exporter.Spans()
+	if len(spans) != 1 || spans[0].Attributes["http.response.status_code"] != http.StatusSwitchingProtocols {
+		t.Errorf(`the handshake span should end at its headers, %v given`, spans)
+	}
+}
+
+func Test_WebSocketPing(t *testing.T) {
+	var pings int32
+	ts := newWebSocketServer(t, &pings, false)
+	defer ts.Close()
+
+	client := NewClient().SetPingInterval(10 * time.Millisecond).SetWebSocketCompression(false)
+	req, _ := client.Get(ts.URL)
+	req.SetBasicAuth("user", "pass").SetCookie(&http.Cookie{Name: "session", Value: "abc"})
+	l := newRecordListener()
+	ws, err := client.NewWebSocket(req, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	ws.Close(CloseGoingAway, "")
+	<-l.closed
+
+	if atomic.LoadInt32(&pings) < 3 {
+		t.Errorf(`Server should receive pings, %d given`, pings)
+	}
+	select {
+	case err := <-l.failed:
+		t.Errorf(`Answered pings should not fail, %v given`, err)
+	default:
+	}
+}
+
+func Test_WebSocketPongTimeout(t *testing.T) {
+	var pings int32
+	ts := newWebSocketServer(t, &pings, true)
+	defer ts.Close()
+
+	client := NewClient().SetPingInterval(10 * time.Millisecond)
+	req, _ := client.Get(ts.URL)
+	req.SetBasicAuth("user", "pass").SetCookie(&http.Cookie{Name: "session", Value: "abc"})
+	l := newRecordListener()
+	if _, err := client.NewWebSocket(req, l); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-l.failed:
+		if err != WebSocketPongTimeout {
+			t.Errorf(`Failure should be %v, %v given`, WebSocketPongTimeout, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("missing pong not reported")
+	}
+}
+
+func Test_WebSocketMaxMessageSize(t *testing.T) {
+	codes := make(chan int, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		rw.WriteString("Sec-WebSocket-Accept: " + webSocketAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+		rw.Flush()
+		// fragments smaller than the limit, whose message is larger
+		part := []byte(strings.Repeat("a", 600))
+		writeWsFrame(conn, false, false, opText, part, false)
+		writeWsFrame(conn, true, false, opContinuation, part, false)
+		f, err := readWsFrame(bufio.NewReader(conn), true)
+		if err != nil || f.opcode != opClose {
+			t.Errorf(`Client should send a close frame, %v given`, err)
+			return
+		}
+		codes <- int(binary.BigEndian.Uint16(f.payload))
+	}))
+	defer ts.Close()
+
+	client := NewClient().SetWebSocketCompression(false).SetWebSocketMaxMessageSize(1000)
+	req, _ := client.Get(ts.URL)
+	l := newRecordListener()
+	if _, err := client.NewWebSocket(req, l); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-l.failed:
+		if err != WebSocketMessageTooBig {
+			t.Errorf(`Failure should be %v, %v given`, WebSocketMessageTooBig, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("too big message not reported")
+	}
+	if code := <-codes; code != CloseMessageTooBig {
+		t.Errorf(`Close code should be %d, %d given`, CloseMessageTooBig, code)
+	}
+
+	// a compressed message is small on the wire and large once inflated
+	var pings int32
+	echo := newWebSocketServer(t, &pings, false)
+	defer echo.Close()
+	req, _ = client.SetWebSocketCompression(true).Get(echo.URL)
+	req.SetBasicAuth("user", "pass").SetCookie(&http.Cookie{Name: "session", Value: "abc"})
+	l = newRecordListener()
+	ws, err := client.NewWebSocket(req, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.Send(strings.Repeat("b", 900))
+	ws.Send(strings.Repeat("c", 5000))
+	if got := <-l.messages; len(got) != 900 {
+		t.Errorf(`Message size should be %d, %d given`, 900, len(got))
+	}
+	select {
+	case err := <-l.failed:
+		if err != WebSocketMessageTooBig {
+			t.Errorf(`Failure should be %v, %v given`, WebSocketMessageTooBig, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("too big inflated message not reported")
+	}
+}
+
+func Test_WebSocketHandshakeFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	client := NewClient()
+	req, _ := client.Get(ts.URL)
+	l := newRecordListener()
+	if _, err := client.NewWebSocket(req, l); err == nil {
+		t.Fatal("handshake should fail")
+	}
+	if err := <-l.failed; err == nil {
+		t.Errorf(`OnFailure should receive the handshake error`)
+	}
+}